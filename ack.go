@@ -0,0 +1,140 @@
+package configloader
+
+import (
+	"fmt"
+	"time"
+)
+
+// ackSub is a subscriber from SubscribeAck. delivered is the fingerprint
+// of the last config it received, and acked the last one it applied.
+type ackSub[Config any] struct {
+	ch        chan Config
+	delivered string
+	acked     string
+}
+
+// SubscribeAck returns a channel that receives each newly loaded config,
+// starting with the current one if any, and a function to call once the
+// last config received has been applied. WaitApplied waits for every
+// such subscriber to apply a config.
+//
+// The channel is unbuffered, so that the loader knows which config each
+// acknowledgement is for. As with SubscribeBlocking, no update is
+// dropped, and a subscriber that stops reading stalls config updates.
+func (b *ConfigLoader[Config]) SubscribeAck() (chan Config, func()) {
+	s := &ackSub[Config]{ch: make(chan Config)}
+	ack := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		s.acked = s.delivered
+		b.notifyAcks()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s.ch, ack
+	}
+	b.ackSubs = append(b.ackSubs, s)
+	if b.conf != nil {
+		// Deliver the current config the way updates are, so that it
+		// can't overtake a later one.
+		go func() {
+			b.updateMu.Lock()
+			defer b.updateMu.Unlock()
+			b.mu.Lock()
+			if b.closed || s.delivered != "" {
+				// The channel is closed, or an update got there first.
+				b.mu.Unlock()
+				return
+			}
+			conf, fprint := *b.conf, b.fprint
+			b.mu.Unlock()
+			b.deliverAck(s, conf, fprint)
+		}()
+	}
+	return s.ch, ack
+}
+
+// deliverAck must be called with b.updateMu held, and not b.mu. It waits
+// for s to receive conf, unless the loader is closed or s unsubscribed
+// first.
+func (b *ConfigLoader[Config]) deliverAck(s *ackSub[Config], conf Config, fprint string) {
+	subscribed := func() bool {
+		for _, sub := range b.ackSubs {
+			if sub == s {
+				return true
+			}
+		}
+		return false
+	}
+	if !b.waitSend(s.ch, b.copyConf(conf), subscribed) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.delivered = fprint
+}
+
+// notifyAcks must be called with b.mu held. It wakes up WaitApplied
+// calls to check their fingerprint again.
+func (b *ConfigLoader[Config]) notifyAcks() {
+	if b.ackWait != nil {
+		close(b.ackWait)
+		b.ackWait = nil
+	}
+}
+
+// carryAcks must be called with b.mu held. When the fingerprint changes
+// from old to fprint without a new config, subscribers that applied old
+// have also applied fprint.
+func (b *ConfigLoader[Config]) carryAcks(old, fprint string) {
+	for _, s := range b.ackSubs {
+		if s.delivered == old {
+			s.delivered = fprint
+		}
+		if s.acked == old {
+			s.acked = fprint
+		}
+	}
+	b.notifyAcks()
+}
+
+// WaitApplied waits until fingerprint is that of the current config and
+// every subscriber from SubscribeAck has acknowledged it. It returns an
+// error if that doesn't happen within timeout, or if the loader is
+// closed first. With no such subscribers, it only waits for the config
+// to be loaded.
+func (b *ConfigLoader[Config]) WaitApplied(fingerprint string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return fmt.Errorf("config loader closed")
+		}
+		applied := b.fprint == fingerprint
+		for _, s := range b.ackSubs {
+			if s.acked != fingerprint {
+				applied = false
+			}
+		}
+		if applied {
+			b.mu.Unlock()
+			return nil
+		}
+		if b.ackWait == nil {
+			b.ackWait = make(chan struct{})
+		}
+		wait := b.ackWait
+		b.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-deadline:
+			return fmt.Errorf("timed out waiting for config %s to be applied", fingerprint)
+		case <-b.ctx.Done():
+			return fmt.Errorf("config loader closed")
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeAck(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"one\"\nbar: \"bar!\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	ch, ack := loader.SubscribeAck()
+	fprint := loader.Fingerprint()
+	if err := loader.WaitApplied(fprint, 50*time.Millisecond); err == nil {
+		t.Errorf("expected WaitApplied to time out before the config is received")
+	}
+	if got := (<-ch).Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+	if err := loader.WaitApplied(fprint, 50*time.Millisecond); err == nil {
+		t.Errorf("expected WaitApplied to time out before the config is acknowledged")
+	}
+	ack()
+	if err := loader.WaitApplied(fprint, time.Second); err != nil {
+		t.Errorf("expected the config to be applied: %v", err)
+	}
+
+	// Delivery waits for the subscriber, so load in the background.
+	loaded := make(chan error, 1)
+	go func() {
+		loaded <- loader.SetConfigReader(strings.NewReader("foo: \"two\"\nbar: \"bar!\"\n"), true)
+	}()
+	applied := make(chan error, 1)
+	conf := <-ch
+	if conf.Foo != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+	}
+	if err := <-loaded; err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	fprint = loader.Fingerprint()
+	go func() {
+		applied <- loader.WaitApplied(fprint, time.Second)
+	}()
+	ack()
+	if err := <-applied; err != nil {
+		t.Errorf("expected the new config to be applied: %v", err)
+	}
+
+	loader.Close()
+	if _, ok := <-ch; ok {
+		t.Errorf("expected ack subscriber channel to be closed")
+	}
+	if err := loader.WaitApplied(fprint, time.Second); err == nil {
+		t.Errorf("expected an error after Close")
+	}
+}
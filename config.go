@@ -1,183 +1,2113 @@
 package configloader
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v2"
 )
 
+// ConfigChange describes a newly loaded config together with the one it
+// replaced. Old is nil for the first config loaded.
+type ConfigChange[Config any] struct {
+	Old *Config
+	New Config
+	// Source is what caused the change, and Time when it was made.
+	Source Source
+	Time   time.Time
+}
+
+// Source identifies what caused a config change.
+type Source int
+
+const (
+	// SourceManual is a call to Load, Reload, WriteConfig, Rollback,
+	// InjectConfig or Resume.
+	SourceManual Source = iota
+	// SourceFsnotify is a filesystem event on a config file.
+	SourceFsnotify
+	// SourcePoll is a periodic poll of the config source.
+	SourcePoll
+	// SourcePathChange is a change of config source, such as by
+	// SetConfigPath, SetConfigReader or SetConfigURL.
+	SourcePathChange
+	// SourceSignal is the signal set with WithReloadSignal.
+	SourceSignal
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceManual:
+		return "manual"
+	case SourceFsnotify:
+		return "fsnotify"
+	case SourcePoll:
+		return "poll"
+	case SourcePathChange:
+		return "path-change"
+	case SourceSignal:
+		return "signal"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// ConfigOrigin says where the config being served came from; see
+// ConfigSource.
+type ConfigOrigin int
+
+const (
+	// FromFile is a config loaded from the config source, whether files,
+	// a reader or a URL, or set with InjectConfig or Rollback.
+	FromFile ConfigOrigin = iota
+	// PreviousCached is a config kept because the last attempt to load
+	// a new one failed, so it may be stale.
+	PreviousCached
+	// Default is a config not loaded from the source: the zero config,
+	// with defaults applied, served when optional files don't exist, the
+	// config from WithRequiredMissingHandler or WithEagerDefault, or no
+	// config at all yet.
+	Default
+)
+
+func (o ConfigOrigin) String() string {
+	switch o {
+	case FromFile:
+		return "file"
+	case PreviousCached:
+		return "previous"
+	case Default:
+		return "default"
+	}
+	return fmt.Sprintf("ConfigOrigin(%d)", int(o))
+}
+
+// CallbackHandle identifies a callback added with AddCallback.
+type CallbackHandle int
+
+type callbackEntry[Config any] struct {
+	handle CallbackHandle
+	fn     func(old *Config, new Config) (Config, error)
+}
+
 type ConfigLoader[Config any] struct {
-	mu      sync.Mutex
-	path    string
+	mu       sync.Mutex
+	paths    []string
+	required bool
+
+	// memSource is set when the config comes from SetConfigReader
+	// rather than from files; memData holds its contents.
+	memSource bool
+	memData   []byte
+
+	// included lists the files pulled in by include directives during
+	// the last load, so that they are watched too.
+	included []string
+
+	// fallback is read instead of the config path if it doesn't exist;
+	// see SetConfigPathWithFallback.
+	fallback string
+
+	// glob, if set, is the pattern the config paths are expanded from at
+	// each load; see SetConfigGlob.
+	glob string
+
+	// stamps records the size and modification time of each config file
+	// when it was last read, for WithStatCheck.
+	stamps map[string]fileStamp
+
+	// url is set when the config comes from SetConfigURL. The last
+	// response body and its ETag are kept to make polling cheap.
+	url         string
+	urlInterval time.Duration
+	etag        string
+	urlData     []byte
+
 	fprint  string
 	conf    *Config
+	lastErr error
+	stats   Stats
+	opts    options
+
+	// history holds recently loaded configs, oldest first. rolledBack is
+	// the fingerprint of the source config replaced by Rollback, which
+	// is not reloaded until the source changes.
+	history    []ConfigSnapshot[Config]
+	rolledBack string
+
+	subs      []chan Config
+	chgSubs   []chan ConfigChange[Config]
+	errSubs   []chan error
+	blockSubs []chan Config
+	// keepSubs are subscribers that keep the configs already in their
+	// channel, dropping new ones, when it is full.
+	keepSubs []chan Config
+	// watchEvents receives the watcher's file events; see WatchEvents.
+	watchEvents chan WatchEvent
+	fieldSubs   []fieldSub
+	ackSubs     []*ackSub[Config]
+	// ackWait is closed when an acknowledgement or a new fingerprint may
+	// satisfy WaitApplied.
+	ackWait chan struct{}
+
+	// updateMu serializes updates; pending holds a newly stored config,
+	// with fingerprint pendingFprint, to be delivered to blockSubs and
+	// ackSubs once mu is released.
+	updateMu      sync.Mutex
+	pending       *Config
+	pendingFprint string
+	// unsubscribed is closed when Unsubscribe removes a subscriber that
+	// a delivery may be waiting on; see waitSend.
+	unsubscribed chan struct{}
+
+	// lastBroadcast is when subscribers were last sent a config. While
+	// WithMinBroadcastInterval holds a broadcast back, heldBack is set
+	// and heldOld is the config subscribers last saw.
+	lastBroadcast time.Time
+	heldBack      bool
+	heldOld       *Config
+
+	// source is what caused the current update; changedBy and changedAt
+	// describe the update that stored the current config.
+	source    Source
+	changedBy Source
+	changedAt time.Time
+	// origin is where the current config came from.
+	origin ConfigOrigin
+
+	clone         func(Config) Config
+	onMissing     func() (Config, error)
+	partialDecode func(map[string]any, error) (Config, error)
+	// partialErr is the decode error behind a config salvaged by
+	// partialDecode, reported by LastError until another config is
+	// stored.
+	partialErr error
+
+	// overrides are the fields set by SetOverride, by key path.
+	// forceDecode makes a load decode the config even if its source is
+	// unchanged, so that changed overrides are applied.
+	overrides   map[string]any
+	forceDecode bool
+	// transform, if set, decodes a config of another type with decode
+	// and converts it; see NewTransformingLoader.
+	transform func(decode func(any) error) (*Config, error)
+	callbacks []callbackEntry[Config]
+	nextCbID  CallbackHandle
+	// rawCallbacks vet each config file's contents before it is decoded.
+	rawCallbacks []func([]byte) error
+
+	// projections are run on each newly stored config, after it has been
+	// delivered to blocking subscribers, to update the loaders derived
+	// from this one by Project. derived is set on those loaders, which
+	// can't be loaded themselves, and children closes them.
+	projections []func(Config)
+	derived     bool
+	children    []func()
+
 	control chan string
-	subs    []chan Config
+	// rewatchDone, if not nil, is closed once the watcher watches the
+	// current paths; see waitRewatch. watching is set once there is a
+	// watcher to wait for.
+	rewatchDone chan struct{}
+	watching    bool
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+	done        chan struct{}
+	sigs        chan os.Signal
+	closeOnce   sync.Once
+	haltOnce    sync.Once
+	closed      bool
+	paused      bool
+	frozen      bool
+	quiet       bool
+}
+
+// NewWithOptions creates a loader configured entirely by options, with
+// the config path, if any, given by WithPath. Like NewConfigLoader, it
+// might return an error and a valid config loader; errors from invalid or
+// conflicting options are returned with a nil loader.
+//
+// Option is not parameterized by the config type, so that the same
+// options can be shared between loaders of different types. The few
+// options that depend on it, such as WithCloneFunc, are checked against
+// the loader's type here.
+func NewWithOptions[Config any](opts ...Option) (*ConfigLoader[Config], error) {
+	return NewConfigLoaderContext[Config](context.Background(), "", opts...)
+}
+
+// This might return an error and a valid config loader. Errors from
+// invalid options are returned with a nil loader.
+func NewConfigLoader[Config any](path string, opts ...Option) (ret *ConfigLoader[Config], err error) {
+	return NewConfigLoaderContext[Config](context.Background(), path, opts...)
+}
+
+// LoadFrom creates a loader for the config at path, as NewConfigLoader
+// does, for programs that want their config or nothing at startup: if the
+// initial load fails, it closes the loader and returns only the error,
+// rather than a loader serving the default config. The loader goes on to
+// watch path for changes as usual. If required is false, a missing file
+// is not an error, and the zero config is served until it appears.
+func LoadFrom[Config any](path string, required bool, opts ...Option) (*ConfigLoader[Config], error) {
+	if path == "" {
+		return nil, fmt.Errorf("no config path specified")
+	}
+	loader, err := newConfigLoader[Config](context.Background(), path, required, nil, nil, opts)
+	if err != nil {
+		if loader != nil {
+			loader.Close()
+		}
+		return nil, err
+	}
+	return loader, nil
+}
+
+// NewConfigLoaderContext is like NewConfigLoader, but the loader stops
+// watching for changes when ctx is done, as if Close had been called.
+func NewConfigLoaderContext[Config any](ctx context.Context, path string, opts ...Option) (ret *ConfigLoader[Config], err error) {
+	return newConfigLoader[Config](ctx, path, true, nil, nil, opts)
 }
 
-// This might return an error and a valid config loader.
-func NewConfigLoader[Config any](path string) (ret *ConfigLoader[Config], err error) {
-	//log.Printf("NewBotConfigLoader")
+// newConfigLoader creates a loader, whose first load is of path, which is
+// required if required is set. transform, if not nil, is set before the
+// first load; see NewTransformingLoader. If shared is not nil, the loader
+// is a section of it and is watched by its watcher rather than its own.
+func newConfigLoader[Config any](ctx context.Context, path string, required bool, transform func(decode func(any) error) (*Config, error), shared *MultiLoader, opts []Option) (ret *ConfigLoader[Config], err error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, fmt.Errorf("invalid option: %v", err)
+		}
+	}
+	if err := o.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %v", err)
+	}
+	if o.pathSet {
+		if path != "" {
+			return nil, fmt.Errorf("invalid options: path %q conflicts with WithPath(%q)", path, o.path)
+		}
+		path = o.path
+	}
+	var clone func(Config) Config
+	if o.clone != nil {
+		var ok bool
+		if clone, ok = o.clone.(func(Config) Config); !ok {
+			return nil, fmt.Errorf("invalid options: clone function %T does not match config type", o.clone)
+		}
+	}
+	var onMissing func() (Config, error)
+	if o.onMissing != nil {
+		var ok bool
+		if onMissing, ok = o.onMissing.(func() (Config, error)); !ok {
+			return nil, fmt.Errorf("invalid options: missing config handler %T does not match config type", o.onMissing)
+		}
+	}
+	var partialDecode func(map[string]any, error) (Config, error)
+	if o.partialDecode != nil {
+		var ok bool
+		if partialDecode, ok = o.partialDecode.(func(map[string]any, error) (Config, error)); !ok {
+			return nil, fmt.Errorf("invalid options: partial decode function %T does not match config type", o.partialDecode)
+		}
+	}
+
 	ret = &ConfigLoader[Config]{
 		control: make(chan string, 1),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+		opts:    o,
+		clone:   clone,
+
+		onMissing:     onMissing,
+		partialDecode: partialDecode,
+		transform:     transform,
+	}
+	ret.ctx, ret.cancel = context.WithCancel(ctx)
+	ret.origin = Default
+	if o.watchEvents > 0 {
+		ret.watchEvents = make(chan WatchEvent, o.watchEvents)
+	}
+
+	// The default config is set rather than stored, so it isn't
+	// broadcast, recorded in the history, or given a fingerprint that
+	// could stop the real config from loading.
+	if o.eagerDefault {
+		if ret.conf, err = ret.defaultConfig(); err != nil {
+			ret.cancel()
+			return nil, fmt.Errorf("invalid default config: %v", err)
+		}
+		ret.changedAt = time.Now()
+	}
+
+	// Decide how changes are watched for before returning, so that Stats
+	// and IsPolling report it from the start. The watcher is handed to
+	// the watch goroutine, which closes it.
+	var w *fsnotify.Watcher
+	if shared != nil {
+		ret.control = shared.control
+		ret.stats.WatchMode = shared.mode
+	} else if o.fsys != nil || o.noWatch {
+		ret.stats.WatchMode = WatchModeNone
+	} else if w, err = fsnotify.NewWatcher(); err != nil {
+		ret.logf("fsnotify error, falling back to polling: %v", err)
+		ret.stats.WatchMode = WatchModePolling
+	} else {
+		ret.stats.WatchMode = WatchModeFsnotify
 	}
 
-	err = ret.Load(path)
+	err = ret.loadPath(path, required)
 	if err != nil {
-		log.Printf("config error: %v", err)
+		ret.logf("config error: %v", err)
+	}
+	if shared != nil {
+		// The MultiLoader handles signals and watches for changes; Section
+		// waits for it to watch the paths once the loader is added.
+		ret.startWatching()
+		return
+	}
+
+	// Install the signal handler before returning, so that a signal sent
+	// right after construction isn't handled by the default action.
+	if o.reloadSignal != nil {
+		ret.sigs = make(chan os.Signal, 1)
+		signal.Notify(ret.sigs, o.reloadSignal)
+	}
+
+	if o.noWatch {
+		close(ret.stopped)
+		return
 	}
 
-	// Periodically reload the config.
-	go ret.watch()
+	// Periodically reload the config. Wait for the paths to be watched,
+	// so that a change made as soon as the loader is returned is seen.
+	ret.startWatching()
+	go ret.watch(w)
+	ret.waitRewatch()
 
 	return
 }
 
+// startWatching marks the loader as watched, and asks the watcher to watch
+// its paths, so that waitRewatch waits for that.
+func (b *ConfigLoader[Config]) startWatching() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watching = true
+	b.requestRewatch()
+}
+
+// logf logs through the loader's Logger.
+func (b *ConfigLoader[Config]) logf(format string, v ...any) {
+	b.opts.logger.Printf(format, v...)
+}
+
+// Close stops watching for changes and closes every channel returned by
+// the Subscribe methods, so that loops ranging over them end. It is safe
+// to call more than once.
 func (b *ConfigLoader[Config]) Close() {
-	b.control <- "done"
-	close(b.control)
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+		b.cancel()
+
+		// Wait out any update in progress, which may be delivering to
+		// blocking subscribers outside the lock; cancelling stops it
+		// waiting on them.
+		b.updateMu.Lock()
+		b.mu.Lock()
+		b.closeSubs()
+		children := b.children
+		b.children = nil
+		close(b.done)
+		b.mu.Unlock()
+		b.updateMu.Unlock()
+
+		for _, closeChild := range children {
+			closeChild()
+		}
+	})
+}
+
+// Done returns a channel that is closed when the loader is closed, either
+// by Close or by its context being done.
+func (b *ConfigLoader[Config]) Done() <-chan struct{} {
+	return b.done
 }
 
+// closeSubs must be called with b.mu held. It closes and forgets every
+// subscriber channel, so that nothing can be sent on them afterwards.
+func (b *ConfigLoader[Config]) closeSubs() {
+	for _, ch := range b.subs {
+		close(ch)
+	}
+	for _, ch := range b.blockSubs {
+		close(ch)
+	}
+	for _, ch := range b.keepSubs {
+		close(ch)
+	}
+	for _, ch := range b.chgSubs {
+		close(ch)
+	}
+	for _, ch := range b.errSubs {
+		close(ch)
+	}
+	for _, sub := range b.fieldSubs {
+		close(sub.ch)
+	}
+	for _, sub := range b.ackSubs {
+		close(sub.ch)
+	}
+	b.subs, b.blockSubs, b.keepSubs, b.chgSubs, b.errSubs, b.fieldSubs, b.ackSubs = nil, nil, nil, nil, nil, nil, nil
+	if b.watchEvents != nil {
+		// It is kept, closed, for later WatchEvents calls.
+		close(b.watchEvents)
+	}
+}
+
+// Subscribe returns a channel that receives each newly loaded config. It
+// is SubscribeWithReplay(true): if a config is already loaded, it is sent
+// first. A slow reader gets the latest config rather than a backlog, as
+// with SubscribeWith(DropOldest).
 func (b *ConfigLoader[Config]) Subscribe() chan Config {
-	ret := make(chan Config, 1)
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.subs = append(b.subs, ret)
-	ret <- *b.conf
-	return ret
+	return b.SubscribeWithReplay(true)
 }
 
-func (b *ConfigLoader[Config]) SetConfigPath(path string) error {
-	b.mu.Lock()
-	if b.path == path {
-		return nil
+// DropPolicy says what happens when a config is loaded while a
+// subscriber's channel is full.
+type DropPolicy int
+
+const (
+	// DropOldest replaces the stale config in the channel with the new
+	// one, so the reader always catches up to the latest. This is what
+	// Subscribe does.
+	DropOldest DropPolicy = iota
+	// DropNewest keeps the configs already in the channel and drops the
+	// new one, so the reader sees what it was sent first.
+	DropNewest
+	// Block waits for room in the channel, as SubscribeBlocking does.
+	Block
+)
+
+// SubscribeWith is like Subscribe, but policy says what to do when the
+// channel is full. Drops are counted in Stats.DroppedUpdates.
+func (b *ConfigLoader[Config]) SubscribeWith(policy DropPolicy) chan Config {
+	switch policy {
+	case DropNewest:
+		ret := make(chan Config, b.opts.subscribeBuffer)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.closed {
+			close(ret)
+			return ret
+		}
+		b.keepSubs = append(b.keepSubs, ret)
+		if b.conf != nil {
+			ret <- b.copyConf(*b.conf)
+		}
+		return ret
+	case Block:
+		return b.SubscribeBlocking()
+	default:
+		return b.Subscribe()
 	}
-	b.mu.Unlock()
-	b.control <- "update"
-	return b.Load(path)
 }
 
-func (b *ConfigLoader[Config]) Load(path string) error {
+// SubscribeWithReplay returns a channel that receives each newly loaded
+// config. If replay is set and a config is already loaded, the channel
+// starts with it; otherwise the first value is the next config loaded.
+// Either way, each config is delivered at most once: the replayed config
+// is never repeated by the broadcast that loaded it, since subscribing and
+// loading both happen under the loader's lock.
+func (b *ConfigLoader[Config]) SubscribeWithReplay(replay bool) chan Config {
+	ret := make(chan Config, b.opts.subscribeBuffer)
 	b.mu.Lock()
 	defer b.mu.Unlock()
-
-	if path != "" {
-		b.path = path
+	if b.closed {
+		close(ret)
+		return ret
+	}
+	b.subs = append(b.subs, ret)
+	if replay && b.conf != nil {
+		ret <- b.copyConf(*b.conf)
 	}
+	return ret
+}
 
-	if b.path == "" {
-		return fmt.Errorf("no config path specified")
+// WaitForConfig returns the current config, or if none has been loaded
+// yet, waits until one is. It returns an error if ctx is done or the
+// loader is closed first.
+func (b *ConfigLoader[Config]) WaitForConfig(ctx context.Context) (*Config, error) {
+	b.mu.Lock()
+	if b.conf != nil {
+		defer b.mu.Unlock()
+		return b.copyConfPtr(b.conf), nil
 	}
-	configBytes, err := os.ReadFile(b.path)
-	if err != nil {
-		return fmt.Errorf("could not read config @ %q: %v", b.path, err)
+	if b.closed {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("config loader closed")
 	}
-	if len(configBytes) < 10 {
-		return fmt.Errorf("empty or truncated config")
+	ch := make(chan Config, 1)
+	b.subs = append(b.subs, ch)
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = removeChan(b.subs, ch)
+	}()
+	select {
+	case conf, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("config loader closed")
+		}
+		return &conf, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.ctx.Done():
+		return nil, fmt.Errorf("config loader closed")
 	}
+}
 
-	fprint := fmt.Sprintf("%x", sha256.Sum256(configBytes))
-	if fprint == b.fprint {
-		// Same as before, end early.
-		return nil
+// removeChan returns chans without ch.
+func removeChan[T any](chans []chan T, ch chan T) []chan T {
+	for i, c := range chans {
+		if c == ch {
+			return append(chans[:i:i], chans[i+1:]...)
+		}
 	}
+	return chans
+}
 
-	conf := new(Config)
-	err = yaml.Unmarshal(configBytes, conf)
-	if err != nil {
-		return fmt.Errorf("could not read config %q: %v", b.path, err)
+// containsChan reports whether ch is in chans.
+func containsChan[T any](chans []chan T, ch chan T) bool {
+	for _, c := range chans {
+		if c == ch {
+			return true
+		}
 	}
-	log.Printf("read config %q, with hash: %s", b.path, fprint)
+	return false
+}
 
-	// store the config
-	b.conf = conf
-	b.fprint = fprint
+// Unsubscribe stops sending configs on ch, which was returned by one of
+// the Subscribe methods that deliver configs, and closes it. It reports
+// whether ch was subscribed. A delivery to ch that is waiting for room is
+// abandoned, so a blocking subscriber can unsubscribe without reading
+// first.
+func (b *ConfigLoader[Config]) Unsubscribe(ch chan Config) bool {
+	b.mu.Lock()
+	switch {
+	case containsChan(b.subs, ch):
+		b.subs = removeChan(b.subs, ch)
+	case containsChan(b.keepSubs, ch):
+		b.keepSubs = removeChan(b.keepSubs, ch)
+	case containsChan(b.blockSubs, ch):
+		b.blockSubs = removeChan(b.blockSubs, ch)
+		b.unsubscribeWaiting(ch)
+		return true
+	default:
+		for i, s := range b.ackSubs {
+			if s.ch == ch {
+				b.ackSubs = append(b.ackSubs[:i:i], b.ackSubs[i+1:]...)
+				// WaitApplied no longer waits for it.
+				b.notifyAcks()
+				b.unsubscribeWaiting(ch)
+				return true
+			}
+		}
+		b.mu.Unlock()
+		return false
+	}
+	// These are only sent to with b.mu held.
+	close(ch)
+	b.mu.Unlock()
+	return true
+}
 
-	// broadcast
-	for _, s := range b.subs {
+// SubscribeContext is like Subscribe, but unsubscribes the channel, closing
+// it, once ctx is done.
+func (b *ConfigLoader[Config]) SubscribeContext(ctx context.Context) <-chan Config {
+	ch := b.Subscribe()
+	go func() {
 		select {
-		case s <- *conf:
-		default:
-			log.Println("subscriber channel is full")
+		case <-ctx.Done():
+			b.Unsubscribe(ch)
+		case <-b.ctx.Done():
+			// Close closes ch.
 		}
+	}()
+	return ch
+}
+
+// unsubscribeWaiting must be called with b.mu held, which it releases.
+// It closes ch, which has been removed from its subscriber list, once no
+// delivery is waiting to send on it.
+func (b *ConfigLoader[Config]) unsubscribeWaiting(ch chan Config) {
+	if b.unsubscribed != nil {
+		close(b.unsubscribed)
+		b.unsubscribed = nil
 	}
+	b.mu.Unlock()
 
-	return nil
+	b.updateMu.Lock()
+	defer b.updateMu.Unlock()
+	close(ch)
 }
 
-func (b *ConfigLoader[Config]) watch() {
+// SubscriberCount returns the number of channels subscribed to configs
+// with Subscribe, SubscribeWith, SubscribeBlocking, SubscribeAck and the
+// like, including those used by OnChange and pending WaitForConfig calls.
+// It is meant for finding subscribers that are never unsubscribed.
+func (b *ConfigLoader[Config]) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) + len(b.keepSubs) + len(b.blockSubs) + len(b.ackSubs)
+}
 
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Printf("fsnotify error: %v", err)
-		log.Printf("polling config file: %s", b.path)
+// OnChange runs fn with the current config, if there is one, and again
+// after every change, until the loader is closed. fn runs on its own
+// goroutine; if updates arrive faster than fn handles them, it is called
+// with the latest. A panic in fn is logged and otherwise ignored.
+func (b *ConfigLoader[Config]) OnChange(fn func(Config)) {
+	ch := b.Subscribe()
+	go func() {
 		for {
 			select {
-			case <-time.After(time.Second * 10):
-				b.Load("")
-			case cmd := <-b.control:
-				if cmd == "done" {
-					log.Printf("exiting config pool loop")
+			case conf, ok := <-ch:
+				if !ok {
 					return
 				}
+				b.runOnChange(fn, conf)
+			case <-b.ctx.Done():
+				return
 			}
 		}
+	}()
+}
+
+// runCallback runs a config callback, turning a panic into an error so
+// that it rejects the config rather than killing the watcher.
+func runCallback[Config any](fn func(*Config, Config) (Config, error), old *Config, conf Config) (ret Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("callback panicked: %v", r)
+		}
+	}()
+	return fn(old, conf)
+}
+
+// ignoreOld adapts a callback that only looks at the new config.
+func ignoreOld[Config any](cb func(Config) (Config, error)) func(*Config, Config) (Config, error) {
+	return func(_ *Config, conf Config) (Config, error) {
+		return cb(conf)
+	}
+}
+
+func (b *ConfigLoader[Config]) runOnChange(fn func(Config), conf Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logf("panic in config change handler: %v", r)
+		}
+	}()
+	fn(conf)
+}
+
+// SubscribeBlocking is like Subscribe, but never drops updates: delivery
+// waits until there is room in the channel. The wait happens without
+// holding the loader's lock, but it does hold up further reloads, so a
+// consumer that stops reading stalls config updates for every subscriber.
+// Likewise, calling into the loader's reload methods from the goroutine
+// that reads the channel can deadlock.
+func (b *ConfigLoader[Config]) SubscribeBlocking() chan Config {
+	ret := make(chan Config, b.opts.subscribeBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ret)
+		return ret
+	}
+	b.blockSubs = append(b.blockSubs, ret)
+	if b.conf != nil {
+		ret <- b.copyConf(*b.conf)
+	}
+	return ret
+}
+
+// SubscribeChanges is like Subscribe, but delivers the previous config
+// alongside each new one so that callers can see what changed.
+func (b *ConfigLoader[Config]) SubscribeChanges() chan ConfigChange[Config] {
+	ret := make(chan ConfigChange[Config], 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ret)
+		return ret
+	}
+	b.chgSubs = append(b.chgSubs, ret)
+	if b.conf != nil {
+		ret <- ConfigChange[Config]{New: b.copyConf(*b.conf), Source: b.changedBy, Time: b.changedAt}
+	}
+	return ret
+}
+
+// SubscribeErrors returns a channel that receives an error whenever a
+// config fails to decode or is rejected by a registered callback. The
+// previous config stays in effect. Errors are dropped if the channel is
+// full.
+func (b *ConfigLoader[Config]) SubscribeErrors() chan error {
+	ret := make(chan error, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ret)
+		return ret
+	}
+	b.errSubs = append(b.errSubs, ret)
+	return ret
+}
+
+// RegisterCallback sets a function that is run on every newly loaded
+// config before it is stored. It may modify the config, e.g. to fill in
+// defaults, or return an error to reject it and keep the previous one.
+//
+// RegisterCallback replaces any callbacks registered earlier, including
+// those added with AddCallback.
+func (b *ConfigLoader[Config]) RegisterCallback(cb func(Config) (Config, error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextCbID++
+	b.callbacks = []callbackEntry[Config]{{handle: b.nextCbID, fn: ignoreOld(cb)}}
+}
+
+// AddCallback appends a function to the chain of callbacks run on every
+// newly loaded config. Callbacks run in the order they were added, each
+// receiving the output of the previous one. If any of them returns an
+// error, the config is rejected and the previous one is kept. The returned
+// handle can be passed to RemoveCallback.
+func (b *ConfigLoader[Config]) AddCallback(cb func(Config) (Config, error)) CallbackHandle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextCbID++
+	b.callbacks = append(b.callbacks, callbackEntry[Config]{handle: b.nextCbID, fn: ignoreOld(cb)})
+	return b.nextCbID
+}
+
+// AddTransitionCallback is like AddCallback, but cb also receives the
+// current config, or nil if none has been loaded yet, so that it can
+// validate the change itself; for example, rejecting a limit that grows
+// by more than some factor at once. cb must not modify old. Transition
+// callbacks share a chain with those added by AddCallback, and the handle
+// can likewise be passed to RemoveCallback.
+func (b *ConfigLoader[Config]) AddTransitionCallback(cb func(old *Config, new Config) (Config, error)) CallbackHandle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextCbID++
+	b.callbacks = append(b.callbacks, callbackEntry[Config]{handle: b.nextCbID, fn: cb})
+	return b.nextCbID
+}
+
+// RemoveCallback removes a callback added with AddCallback. It reports
+// whether the callback was found.
+func (b *ConfigLoader[Config]) RemoveCallback(handle CallbackHandle) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, cb := range b.callbacks {
+		if cb.handle == handle {
+			b.callbacks = append(b.callbacks[:i:i], b.callbacks[i+1:]...)
+			return true
+		}
 	}
+	return false
+}
+
+// ClearCallbacks removes all callbacks, including raw callbacks.
+func (b *ConfigLoader[Config]) ClearCallbacks() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.callbacks = nil
+	b.rawCallbacks = nil
+}
+
+// RegisterRawCallback adds a function that is run on the contents of each
+// config file before it is decoded, e.g. to enforce a policy on the text
+// itself. If it returns an error, the config is rejected and the previous
+// one is kept. Raw callbacks only run when the contents have changed.
+func (b *ConfigLoader[Config]) RegisterRawCallback(cb func([]byte) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rawCallbacks = append(b.rawCallbacks, cb)
+}
+
+// runRawCallback runs cb on data, turning a panic into an error.
+func runRawCallback(cb func([]byte) error, data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("callback panicked: %v", r)
+		}
+	}()
+	return cb(data)
+}
 
-	defer w.Close()
+func (b *ConfigLoader[Config]) SetConfigPath(path string) error {
+	_, err := b.SetConfigPathAndGet(path)
+	return err
+}
 
+// SetConfigPathAndGet is like SetConfigPath, but also returns the config
+// loaded from path, as of the load, which a later Config call could miss
+// if the file changes in between. It returns nil if there is an error.
+func (b *ConfigLoader[Config]) SetConfigPathAndGet(path string) (*Config, error) {
 	b.mu.Lock()
-	path := b.path
+	if b.isOnlyPath(path) {
+		defer b.mu.Unlock()
+		return b.copyConfPtr(b.conf), nil
+	}
 	b.mu.Unlock()
+	return b.setConfigPaths([]string{path}, true)
+}
 
-	log.Printf("watching config file: %s", b.path)
-	w.Add(filepath.Dir(path))
-	for {
-		select {
-		case cmd := <-b.control:
-			if cmd == "done" {
-				log.Printf("exiting config pool loop")
-				return
-			}
-			if cmd == "update" {
-				oldpath := path
-				b.mu.Lock()
-				path = b.path
-				b.mu.Unlock()
-				log.Printf("updating config watch path to: %q", path)
-				w.Remove(filepath.Dir(oldpath))
-				w.Add(filepath.Dir(b.path))
-			}
-		case _, ok := <-w.Errors:
-			if !ok {
-				log.Printf("fsnotify closed")
-				return
-			}
-			log.Printf("fsnotify error: %v", err)
-		case event, ok := <-w.Events:
-			if !ok {
-				log.Printf("fsnotify closed")
-				return
-			}
-			if event.Has(fsnotify.Write) {
-				b.Load("")
-			}
-		case <-time.After(time.Second * 10):
-			b.Load("")
+// SetConfigPaths loads a config merged from several files. Each file is
+// decoded in order onto the same value, so keys in later files override
+// those in earlier ones. If required is false, missing files are skipped,
+// and the zero config is served if none of them exist.
+func (b *ConfigLoader[Config]) SetConfigPaths(paths []string, required bool) error {
+	_, err := b.setConfigPaths(paths, required)
+	return err
+}
+
+// setConfigPaths is SetConfigPaths, returning the config it loaded.
+func (b *ConfigLoader[Config]) setConfigPaths(paths []string, required bool) (*Config, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config path specified")
+	}
+	// Switch paths and load in one update, so that the load here is the
+	// only one for the new paths: the watcher only re-points its watch
+	// and can't slip in a load of its own in between.
+	var conf *Config
+	err := b.update(func() error {
+		b.setPaths(paths, required)
+		b.source = SourcePathChange
+		if err := b.load(); err != nil {
+			return err
 		}
+		conf = b.copyConfPtr(b.conf)
+		return nil
+	})
+	b.waitRewatch()
+	return conf, err
+}
+
+// SetConfigPathWithFallback loads the config from primary, or from
+// fallback if primary doesn't exist, e.g. a machine-specific config with a
+// shared baseline. Both are watched, so removing primary switches to
+// fallback and recreating it switches back. If required is false and
+// neither exists, the zero config is served.
+func (b *ConfigLoader[Config]) SetConfigPathWithFallback(primary, fallback string, required bool) error {
+	if primary == "" || fallback == "" {
+		return fmt.Errorf("no config path specified")
 	}
+	err := b.update(func() error {
+		b.setPaths([]string{primary}, required)
+		b.fallback = b.resolvePath(fallback)
+		b.source = SourcePathChange
+		return b.load()
+	})
+	b.waitRewatch()
+	return err
 }
 
-func (b *ConfigLoader[Config]) Config() (conf *Config) {
+// SetConfigGlob loads a config merged from every file matching pattern,
+// in lexical order, as with SetConfigPaths; e.g. "conf.d/*.yaml" for
+// drop-in fragments. The pattern is expanded again at each load, and its
+// directory is watched, so adding, removing or changing a fragment
+// re-merges the config. Only the last element of the pattern may contain
+// wildcards. If required is true, no matches is an error; otherwise the
+// zero config is served.
+func (b *ConfigLoader[Config]) SetConfigGlob(pattern string, required bool) error {
+	if pattern == "" {
+		return fmt.Errorf("no config path specified")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid config glob %q: %w", pattern, err)
+	}
+	if strings.ContainsAny(filepath.Dir(pattern), "*?[") {
+		return fmt.Errorf("invalid config glob %q: only the file name may contain wildcards", pattern)
+	}
+	err := b.update(func() error {
+		b.setPaths(nil, required)
+		b.glob = b.resolvePath(pattern)
+		b.source = SourcePathChange
+		return b.load()
+	})
+	b.waitRewatch()
+	return err
+}
+
+// isOnlyPath must be called with b.mu held. It reports whether the config
+// is read from path alone, as set by SetConfigPath.
+func (b *ConfigLoader[Config]) isOnlyPath(path string) bool {
+	return len(b.paths) == 1 && b.paths[0] == b.resolvePath(path) && b.fallback == "" && b.glob == ""
+}
+
+// setPaths must be called with b.mu held. It tells the watcher to watch
+// the directories of the new paths, whether or not the files exist yet.
+func (b *ConfigLoader[Config]) setPaths(paths []string, required bool) {
+	b.paths = make([]string, len(paths))
+	for i, path := range paths {
+		b.paths[i] = b.resolvePath(path)
+	}
+	b.included = nil
+	b.fallback = ""
+	b.glob = ""
+	b.stamps = nil
+	b.required = required
+	b.memSource = false
+	b.memData = nil
+	b.url = ""
+	b.etag = ""
+	b.urlData = nil
+	b.requestRewatch()
+}
+
+// resolvePath returns the absolute form of path, so that it doesn't
+// depend on the working directory. Paths in an fs.FS are left alone.
+func (b *ConfigLoader[Config]) resolvePath(path string) string {
+	if b.opts.fsys != nil {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// ConfigPath returns the config file being loaded, as an absolute path,
+// and whether it is required. For a config merged from several files, it
+// returns the last, which takes precedence; see ConfigPaths. For a config
+// from SetConfigURL, it returns the URL, and for one from SetConfigReader,
+// an empty path.
+func (b *ConfigLoader[Config]) ConfigPath() (path string, required bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	conf = b.conf
-	return
+	switch {
+	case b.url != "":
+		return b.url, b.required
+	case b.memSource || len(b.paths) == 0:
+		return "", b.required
+	}
+	return b.paths[len(b.paths)-1], b.required
+}
+
+// ConfigPaths is like ConfigPath, but returns every config file, in the
+// order they are merged.
+func (b *ConfigLoader[Config]) ConfigPaths() (paths []string, required bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.url != "" || b.memSource {
+		return nil, b.required
+	}
+	return append([]string(nil), b.paths...), b.required
+}
+
+// requestRewatch must be called with b.mu held. It tells the watcher to
+// update the set of watched directories.
+func (b *ConfigLoader[Config]) requestRewatch() {
+	if b.closed {
+		return
+	}
+	if b.rewatchDone == nil {
+		b.rewatchDone = make(chan struct{})
+	}
+	// A pending update will pick up the latest paths, so there is no
+	// need to queue another.
+	select {
+	case b.control <- "update":
+	default:
+	}
+}
+
+// pendingWatch returns the paths to watch and, if a rewatch was requested
+// since the watcher last asked, the channel to close once they are
+// watched.
+func (b *ConfigLoader[Config]) pendingWatch() ([]string, chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	done := b.rewatchDone
+	b.rewatchDone = nil
+	return b.filePaths(), done
+}
+
+// filePaths must be called with b.mu held. It returns every file the
+// config may be read from: the configured paths, the fallback, the glob
+// pattern and any included files.
+func (b *ConfigLoader[Config]) filePaths() []string {
+	paths := append([]string(nil), b.paths...)
+	if b.fallback != "" {
+		paths = append(paths, b.fallback)
+	}
+	if b.glob != "" {
+		paths = append(paths, b.glob)
+	}
+	return append(paths, b.included...)
+}
+
+// waitRewatch waits until the watcher watches the current paths, so that
+// changes made as soon as a path change returns aren't missed. It must not
+// be called by the watcher itself.
+func (b *ConfigLoader[Config]) waitRewatch() {
+	b.mu.Lock()
+	done, watching := b.rewatchDone, b.watching
+	b.mu.Unlock()
+	if done == nil || !watching {
+		return
+	}
+	select {
+	case <-done:
+	case <-b.ctx.Done():
+	}
+}
+
+// SetConfigReader loads the config from r instead of from a file, e.g.
+// for a config embedded in the binary. The contents are read once, and
+// nothing is watched while this source is in effect. If required is false
+// and r is nil, the zero config is served.
+func (b *ConfigLoader[Config]) SetConfigReader(r io.Reader, required bool) error {
+	var data []byte
+	if r != nil {
+		var err error
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("could not read config: %v", err)
+		}
+	} else if required {
+		return fmt.Errorf("no config reader specified")
+	}
+
+	return b.update(func() error {
+		b.setPaths(nil, required)
+		b.memSource = true
+		b.memData = data
+		b.source = SourcePathChange
+		return b.load()
+	})
+}
+
+// WriteConfig persists conf to the config path, replacing the file
+// atomically and preserving its mode. The written config is loaded and
+// broadcast right away, so the resulting file event doesn't cause a
+// second reload. A path ending in ".gz" is written gzipped.
+func (b *ConfigLoader[Config]) WriteConfig(conf Config) error {
+	return b.update(func() error {
+		return b.writeConfig(conf)
+	})
+}
+
+// writeConfig must be called with b.mu held.
+func (b *ConfigLoader[Config]) writeConfig(conf Config) error {
+	if b.opts.fsys != nil {
+		return fmt.Errorf("cannot write config to an fs.FS")
+	}
+	if b.transform != nil {
+		return fmt.Errorf("cannot write a transformed config")
+	}
+	if len(b.paths) == 0 {
+		return fmt.Errorf("no config path specified")
+	}
+	if len(b.paths) > 1 {
+		return fmt.Errorf("cannot write config merged from %d files", len(b.paths))
+	}
+	path := b.paths[0]
+
+	// Write in the format the file is read in.
+	data, err := decoderForPath(&b.opts, path).Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("could not marshal config: %v", err)
+	}
+	if data, err = gzipConfig(path, data); err != nil {
+		return fmt.Errorf("could not compress config: %v", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("could not write config @ %q: %v", path, err)
+	}
+
+	return b.load()
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, keeping the mode of any existing file.
+func writeFileAtomic(path string, data []byte) error {
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (b *ConfigLoader[Config]) Load(path string) error {
+	return b.loadPath(path, true)
+}
+
+// loadPath is Load, with path required only if required is set.
+func (b *ConfigLoader[Config]) loadPath(path string, required bool) error {
+	defer b.waitRewatch()
+	return b.update(func() error {
+		if b.frozen {
+			b.logf("frozen, ignoring reload")
+			return nil
+		}
+		if path != "" && !b.isOnlyPath(path) {
+			b.setPaths([]string{path}, required)
+			b.source = SourcePathChange
+		}
+
+		return b.load()
+	})
+}
+
+// update runs fn, which may call load, with b.mu held. Any config it
+// stores is then delivered to blocking subscribers after b.mu has been
+// released, so that a slow consumer doesn't stall readers of the config.
+// Updates are serialized so blocking subscribers see them in order. A
+// loader derived by Project can't be updated, other than by its parent.
+func (b *ConfigLoader[Config]) update(fn func() error) error {
+	if b.derived {
+		return errDerivedLoader
+	}
+	return b.apply(fn)
+}
+
+// waitSend must be called with b.updateMu held, and not b.mu. It waits
+// for ch to receive conf, unless the loader is closed first or ch is
+// unsubscribed, as reported by subscribed under b.mu. It reports whether
+// conf was sent.
+func (b *ConfigLoader[Config]) waitSend(ch chan Config, conf Config, subscribed func() bool) bool {
+	for {
+		b.mu.Lock()
+		if b.closed || !subscribed() {
+			b.mu.Unlock()
+			return false
+		}
+		if b.unsubscribed == nil {
+			b.unsubscribed = make(chan struct{})
+		}
+		unsubscribed := b.unsubscribed
+		b.mu.Unlock()
+
+		select {
+		case ch <- conf:
+			return true
+		case <-unsubscribed:
+			// Check whether it was ch.
+		case <-b.ctx.Done():
+			return false
+		}
+	}
+}
+
+// apply is update without the check that the loader can be updated.
+func (b *ConfigLoader[Config]) apply(fn func() error) error {
+	b.updateMu.Lock()
+	defer b.updateMu.Unlock()
+
+	var (
+		err         error
+		pending     *Config
+		fprint      string
+		subs        []chan Config
+		acks        []*ackSub[Config]
+		projections []func(Config)
+	)
+	func() {
+		// Unlock with defer, so that a panic in fn doesn't leave the
+		// loader locked for a caller that recovers from it.
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		err = fn()
+		b.source = SourceManual
+		pending, fprint = b.pending, b.pendingFprint
+		b.pending = nil
+		subs = append([]chan Config(nil), b.blockSubs...)
+		acks = append([]*ackSub[Config](nil), b.ackSubs...)
+		projections = append(([]func(Config))(nil), b.projections...)
+	}()
+
+	if pending != nil {
+		for _, s := range subs {
+			s := s
+			b.waitSend(s, b.copyConf(*pending), func() bool {
+				return containsChan(b.blockSubs, s)
+			})
+		}
+		for _, s := range acks {
+			b.deliverAck(s, *pending, fprint)
+		}
+		for _, project := range projections {
+			project(*pending)
+		}
+	}
+	return err
+}
+
+// load must be called with b.mu held. It runs the load pipeline and
+// records the outcome for LastError and Stats.
+func (b *ConfigLoader[Config]) load() error {
+	err := b.loadConfigRecover()
+	if b.quiet && isTransient(err) {
+		return err
+	}
+	b.lastErr = err
+	if err == nil {
+		b.lastErr = b.partialErr
+	}
+	if err != nil && b.conf != nil && b.origin != Default {
+		b.origin = PreviousCached
+	}
+	if err != nil {
+		b.stats.ErrorCount++
+		b.stats.LastError = time.Now()
+		b.stats.LastErrorMessage = err.Error()
+	} else {
+		b.stats.LastSuccess = time.Now()
+	}
+	return err
+}
+
+// loadConfigRecover is loadConfig, turning a panic into an error, so that
+// it doesn't take the watcher down with it. Panics while decoding are
+// already turned into errors by decodeDocs; this catches the rest, such
+// as those in reading the config.
+func (b *ConfigLoader[Config]) loadConfigRecover() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic loading config: %v", r)
+			b.logf("%v", err)
+			b.broadcastError(err)
+		}
+	}()
+	return b.loadConfig()
+}
+
+// loadConfig must be called with b.mu held.
+func (b *ConfigLoader[Config]) loadConfig() error {
+	// Stat before reading, so that a write racing with the read shows up
+	// as a change on the next poll.
+	var stamps map[string]fileStamp
+	if b.opts.statCheck {
+		stamps = b.statFiles()
+	}
+	docs, found, err := b.readDocs()
+	if err != nil {
+		return b.handleMissing(err)
+	}
+	b.stamps = stamps
+	origin := FromFile
+	if len(docs) == 0 {
+		origin = Default
+	}
+
+	fprint := b.opts.fingerprint(bytes.Join(docs, nil))
+	if !b.forceDecode && (fprint == b.fprint || fprint == b.rolledBack) {
+		// Same as before, end early.
+		if b.origin == PreviousCached {
+			b.origin = origin
+		}
+		return nil
+	}
+
+	// Templates are rendered after fingerprinting, so a change in the
+	// environment alone doesn't cause a reload.
+	conf, partialErr := b.decodeDocs(docs, found, b.opts.includeKey == "")
+	if partialErr != nil {
+		b.broadcastError(partialErr)
+		if conf == nil {
+			return b.handleRejection(partialErr)
+		}
+	}
+	if partialErr != nil {
+		b.logf("config %q only partially decoded: %v", strings.Join(found, ", "), partialErr)
+	}
+	b.commit(conf, fprint, found, origin, partialErr)
+	return nil
+}
+
+// commit must be called with b.mu held. It makes conf, decoded from found
+// with fingerprint fprint, the current config, coming from origin, unless
+// only its representation changed. partialErr is the decode error if conf
+// was salvaged by WithPartialDecode.
+func (b *ConfigLoader[Config]) commit(conf *Config, fprint string, found []string, origin ConfigOrigin, partialErr error) {
+	source := strings.Join(found, ", ")
+	if b.opts.semanticChanges && b.conf != nil && reflect.DeepEqual(conf, b.conf) {
+		// Only the representation changed. Remember the new fingerprint,
+		// so the same bytes aren't decoded again, but don't broadcast.
+		b.logf("config %q unchanged, with hash: %s", source, fprint)
+		b.carryAcks(b.fprint, fprint)
+		b.fprint = fprint
+		b.rolledBack = ""
+		b.origin = origin
+		b.partialErr = partialErr
+		return
+	}
+	b.logf("read config %q, with hash: %s", source, fprint)
+	if b.opts.changeLogging && b.conf != nil {
+		if changes := b.Diff(*b.conf, *conf); len(changes) > 0 {
+			b.logf("config %q changes: %s", source, formatChanges(changes))
+		}
+	}
+
+	b.store(conf, fprint)
+	b.origin = origin
+	b.partialErr = partialErr
+	b.stats.ReloadCount++
+	b.remember(conf, fprint)
+}
+
+// errNoConfigPath is returned when loading a loader that has no config
+// source.
+var errNoConfigPath = errors.New("no config path specified")
+
+// handleMissing must be called with b.mu held. If err says the config is
+// missing, no config has been loaded yet, and WithRequiredMissingHandler
+// was given, it stores the handler's config instead. Otherwise, or if the
+// handler fails, it returns err.
+func (b *ConfigLoader[Config]) handleMissing(err error) error {
+	if b.onMissing == nil || b.conf != nil || b.quiet {
+		return err
+	}
+	if !errors.Is(err, errNoConfigPath) && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	conf, herr := b.onMissing()
+	if herr != nil {
+		b.logf("missing config handler failed: %v", herr)
+		return err
+	}
+	data, merr := b.opts.decoder.Marshal(conf)
+	if merr != nil {
+		b.logf("could not marshal config from missing config handler: %v", merr)
+		return err
+	}
+	b.logf("%v; using config from missing config handler", err)
+	b.store(&conf, b.opts.fingerprint(data))
+	b.origin = Default
+	b.stats.ReloadCount++
+	return nil
+}
+
+// decodeInto must be called with b.mu held. It decodes docs, read from
+// found, onto v, which must be a pointer, and applies defaults, env
+// overrides and the required field check to it.
+func (b *ConfigLoader[Config]) decodeInto(v any, docs [][]byte, found []string) error {
+	for i, configBytes := range docs {
+		if len(bytes.TrimSpace(configBytes)) == 0 {
+			// An empty file allowed by WithAllowEmpty, which not every
+			// decoder accepts.
+			continue
+		}
+		data, srcLine, err := b.retagDoc(found[i], configBytes, v)
+		if err != nil {
+			return transientError{fmt.Errorf("could not read config %q: %w", found[i], err)}
+		}
+		if err := b.unmarshal(found[i], data, v); err != nil {
+			err = unmarshalError{describeDecodeError(err, configBytes, srcLine)}
+			return transientError{fmt.Errorf("could not read config %q: %w", found[i], err)}
+		}
+	}
+	source := strings.Join(found, ", ")
+	if err := applyDefaults(reflect.ValueOf(v)); err != nil {
+		return fmt.Errorf("could not apply defaults to %q: %w", source, err)
+	}
+	if b.opts.envOverride {
+		if err := applyEnvOverrides(reflect.ValueOf(v), b.opts.envPrefix, b.opts.envSeparators); err != nil {
+			return fmt.Errorf("could not apply env overrides to %q: %w", source, err)
+		}
+	}
+	if missing := missingRequired(reflect.ValueOf(v), "", b.opts.tagName); len(missing) > 0 {
+		return fmt.Errorf("config %q is missing required fields: %s", source, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// decodeDocs must be called with b.mu held. It turns the raw config docs
+// read from found into a config, running the whole pipeline up to and
+// including the callbacks, but without changing the loader's state.
+// Templates are rendered if render is set; files with includes have
+// already been rendered by expandIncludes. If the docs only decode with
+// the WithPartialDecode function, it returns the salvaged config along
+// with the decode error. A panic, say in a decoder tripped up by a
+// malformed file, is returned as an error too, so that every caller, not
+// only load, survives it with b.mu intact.
+func (b *ConfigLoader[Config]) decodeDocs(docs [][]byte, found []string, render bool) (conf *Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			conf, err = nil, fmt.Errorf("panic decoding config: %v", r)
+			b.logf("%v", err)
+		}
+	}()
+	for _, cb := range b.rawCallbacks {
+		for i, configBytes := range docs {
+			if err := runRawCallback(cb, configBytes); err != nil {
+				return nil, rejectionError{fmt.Errorf("config %q rejected: %w", found[i], err)}
+			}
+		}
+	}
+
+	if b.opts.template && render {
+		rendered := make([][]byte, len(docs))
+		for i, configBytes := range docs {
+			var err error
+			rendered[i], err = renderTemplate(found[i], configBytes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		docs = rendered
+	}
+
+	if b.opts.schema != nil && len(docs) > 0 {
+		decoders := make([]Decoder, len(found))
+		for i, name := range found {
+			decoders[i] = decoderForPath(&b.opts, name)
+		}
+		if err := validateSchema(b.opts.schema, decoders, docs); err != nil {
+			return nil, fmt.Errorf("config %q does not match schema: %w", strings.Join(found, ", "), err)
+		}
+	}
+
+	decode := func(v any) error {
+		return b.decodeInto(v, docs, found)
+	}
+	source := strings.Join(found, ", ")
+	var decodeErr error
+	if b.transform != nil {
+		conf, decodeErr = b.transform(decode)
+	} else {
+		conf = new(Config)
+		decodeErr = decode(conf)
+	}
+	if decodeErr != nil {
+		if conf = b.salvage(docs, found, decodeErr); conf == nil {
+			return nil, decodeErr
+		}
+	} else if b.opts.shadow != nil {
+		if err := b.opts.shadow(decode); err != nil {
+			b.logf("%v", err)
+		}
+	}
+	if err := b.applyOverrides(conf); err != nil {
+		return nil, err
+	}
+	for _, cb := range b.callbacks {
+		var err error
+		*conf, err = runCallback(cb.fn, b.conf, *conf)
+		if err != nil {
+			return nil, rejectionError{fmt.Errorf("config %q rejected: %w", source, err)}
+		}
+	}
+	return conf, decodeErr
+}
+
+// store must be called with b.mu held. It makes conf the current config
+// and broadcasts it, unless WithMinBroadcastInterval holds the broadcast
+// back.
+func (b *ConfigLoader[Config]) store(conf *Config, fprint string) {
+	var old *Config
+	if b.conf != nil {
+		prev := *b.conf
+		old = &prev
+	}
+	b.conf = conf
+	b.fprint = fprint
+	b.rolledBack = ""
+	b.origin = FromFile
+	b.changedBy = b.source
+	b.changedAt = time.Now()
+
+	if !b.holdBroadcast(old) {
+		b.broadcast(old, conf, fprint)
+	}
+	b.notifyAcks()
+}
+
+// holdBroadcast must be called with b.mu held. If a config was broadcast
+// less than the WithMinBroadcastInterval interval ago, it arranges for
+// the latest config to be broadcast once the interval is up, with old as
+// the config subscribers last saw, and returns true.
+func (b *ConfigLoader[Config]) holdBroadcast(old *Config) bool {
+	if b.heldBack {
+		// The pending broadcast will send the latest config.
+		return true
+	}
+	wait := b.opts.minBroadcast - time.Since(b.lastBroadcast)
+	if b.opts.minBroadcast <= 0 || wait <= 0 {
+		return false
+	}
+	b.heldBack, b.heldOld = true, old
+	time.AfterFunc(wait, b.flushBroadcast)
+	return true
+}
+
+// flushBroadcast broadcasts the config held back by holdBroadcast.
+func (b *ConfigLoader[Config]) flushBroadcast() {
+	b.apply(func() error {
+		b.releaseBroadcast()
+		return nil
+	})
+}
+
+// releaseBroadcast must be called with b.mu held. It broadcasts the
+// config held back by holdBroadcast, if any.
+func (b *ConfigLoader[Config]) releaseBroadcast() {
+	if b.closed || !b.heldBack {
+		return
+	}
+	old := b.heldOld
+	b.heldBack, b.heldOld = false, nil
+	b.broadcast(old, b.conf, b.fprint)
+}
+
+// broadcast must be called with b.mu held. It sends conf, with
+// fingerprint fprint, to subscribers; old is the config they saw before.
+func (b *ConfigLoader[Config]) broadcast(old, conf *Config, fprint string) {
+	b.lastBroadcast = time.Now()
+	for _, s := range b.subs {
+		if sendLatest(s, b.copyConf(*conf)) {
+			b.stats.DroppedUpdates++
+			b.logf("subscriber channel is full, replaced stale config")
+		}
+	}
+	for _, s := range b.keepSubs {
+		select {
+		case s <- b.copyConf(*conf):
+		default:
+			b.stats.DroppedUpdates++
+			b.logf("subscriber channel is full, dropped new config")
+		}
+	}
+	for _, s := range b.chgSubs {
+		select {
+		case s <- ConfigChange[Config]{Old: b.copyConfPtr(old), New: b.copyConf(*conf), Source: b.changedBy, Time: b.changedAt}:
+		default:
+			b.stats.DroppedUpdates++
+			b.logf("change subscriber channel is full")
+		}
+	}
+	b.broadcastFields(old, conf)
+	if len(b.blockSubs) > 0 || len(b.ackSubs) > 0 || len(b.projections) > 0 {
+		pending := *conf
+		b.pending = &pending
+		b.pendingFprint = fprint
+	}
+}
+
+// readDocs must be called with b.mu held. It returns the raw config
+// documents in merge order, along with a name for each one.
+func (b *ConfigLoader[Config]) readDocs() (docs [][]byte, found []string, err error) {
+	if b.url != "" {
+		data, err := b.fetchURL()
+		if err != nil || data == nil {
+			return nil, nil, err
+		}
+		return [][]byte{data}, []string{b.url}, nil
+	}
+	if b.memSource {
+		if b.memData == nil {
+			return nil, nil, nil
+		}
+		return [][]byte{b.memData}, []string{"<reader>"}, nil
+	}
+
+	if b.glob != "" {
+		if b.paths, err = b.expandGlob(); err != nil {
+			return nil, nil, err
+		}
+		if len(b.paths) == 0 {
+			if b.required {
+				return nil, nil, transientError{fmt.Errorf("no config files match %q: %w", b.glob, fs.ErrNotExist)}
+			}
+			return nil, nil, nil
+		}
+	}
+	if len(b.paths) == 0 {
+		return nil, nil, errNoConfigPath
+	}
+	included := b.included
+	b.included = nil
+	defer func() {
+		if !equalStrings(included, b.included) {
+			b.requestRewatch()
+		}
+	}()
+	for _, path := range b.paths {
+		configBytes, err := b.readFile(path)
+		if errors.Is(err, fs.ErrNotExist) && b.fallback != "" {
+			path = b.fallback
+			configBytes, err = b.readFile(path)
+		}
+		if errors.Is(err, fs.ErrNotExist) && !b.required {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if b.opts.includeKey == "" {
+			docs = append(docs, configBytes)
+			found = append(found, path)
+			continue
+		}
+		d, f, err := b.expandIncludes(path, configBytes, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, d...)
+		found = append(found, f...)
+	}
+	return docs, found, nil
+}
+
+// expandGlob must be called with b.mu held. It returns the files matching
+// the glob pattern, in lexical order.
+func (b *ConfigLoader[Config]) expandGlob() ([]string, error) {
+	if b.opts.fsys != nil {
+		return fs.Glob(b.opts.fsys, b.glob)
+	}
+	return filepath.Glob(b.glob)
+}
+
+// readFile must be called with b.mu held. It reads a single config file,
+// giving up after the WithLoadTimeout timeout, if any.
+func (b *ConfigLoader[Config]) readFile(path string) ([]byte, error) {
+	if b.opts.loadTimeout <= 0 {
+		return b.readFileNow(path)
+	}
+
+	// A read that hangs, say on a stuck network filesystem, can't be
+	// interrupted; leave it to finish, or not, in the background.
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := b.readFileNow(path)
+		done <- result{data, err}
+	}()
+	timer := time.NewTimer(b.opts.loadTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out reading config @ %q after %v", path, b.opts.loadTimeout)
+	case <-b.ctx.Done():
+		return nil, fmt.Errorf("config loader closed while reading config @ %q", path)
+	}
+}
+
+// readFileNow reads a single config file. It only depends on b.opts, so
+// it is safe to call without holding b.mu.
+func (b *ConfigLoader[Config]) readFileNow(path string) ([]byte, error) {
+	var f fs.File
+	var err error
+	if b.opts.fsys != nil {
+		f, err = b.opts.fsys.Open(path)
+	} else {
+		f, err = os.Open(path)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, transientError{fmt.Errorf("could not read config @ %q: %w", path, err)}
+	}
+	if err != nil {
+		return nil, transientError{fmt.Errorf("could not read config @ %q: %v", path, err)}
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, transientError{fmt.Errorf("could not stat config @ %q: %v", path, err)}
+	}
+	// Check the size before reading, so that pointing the loader at a
+	// huge file by mistake doesn't exhaust memory. The read is limited
+	// too, in case the file grows in the meantime.
+	if fi.Size() > b.opts.maxSize {
+		return nil, fmt.Errorf("config %q is %d bytes, more than the maximum of %d", path, fi.Size(), b.opts.maxSize)
+	}
+	configBytes, err := io.ReadAll(io.LimitReader(f, b.opts.maxSize+1))
+	if err != nil {
+		return nil, transientError{fmt.Errorf("could not read config @ %q: %v", path, err)}
+	}
+	if int64(len(configBytes)) > b.opts.maxSize {
+		return nil, fmt.Errorf("config %q is more than the maximum of %d bytes", path, b.opts.maxSize)
+	}
+	// Decompress before the checks below, and before fingerprinting, so
+	// that recompressing the same contents doesn't count as a change. A
+	// truncated gzip file fails here.
+	if configBytes, err = gunzipConfig(path, configBytes, b.opts.maxSize); err != nil {
+		return nil, transientError{err}
+	}
+	// A whitespace-only file is as empty as a zero-byte one.
+	empty := len(bytes.TrimSpace(configBytes)) == 0
+	if empty && !b.opts.allowEmpty {
+		return nil, transientError{fmt.Errorf("empty config %q", path)}
+	}
+	if !empty && len(configBytes) < 10 {
+		return nil, transientError{fmt.Errorf("empty or truncated config %q", path)}
+	}
+	if b.opts.securePerms {
+		if err := checkPerms(path, fi); err != nil {
+			return nil, err
+		}
+	}
+	return configBytes, nil
+}
+
+// checkPerms returns an error if the file at path, described by fi, is
+// group or world writable.
+func checkPerms(path string, fi fs.FileInfo) error {
+	if bad := fi.Mode().Perm() & 0o022; bad != 0 {
+		return fmt.Errorf("config %q has insecure mode %v: writable by group or others (%#o)", path, fi.Mode().Perm(), uint32(bad))
+	}
+	return nil
+}
+
+// unmarshal decodes data read from name with the appropriate decoder,
+// honoring strict mode.
+func (b *ConfigLoader[Config]) unmarshal(name string, data []byte, v any) error {
+	dec := decoderForPath(&b.opts, name)
+	if sd, ok := dec.(StrictDecoder); ok && b.opts.strict {
+		return sd.UnmarshalStrict(data, v)
+	}
+	return dec.Unmarshal(data, v)
+}
+
+// sendLatest sends v on ch. If ch is full, its oldest value is discarded
+// to make room, so that a slow reader always ends up with the latest value
+// rather than a stale one. It reports whether a value was discarded. There
+// must be only one sender on ch.
+func sendLatest[T any](ch chan T, v T) (replaced bool) {
+	for {
+		select {
+		case ch <- v:
+			return replaced
+		default:
+		}
+		select {
+		case <-ch:
+			replaced = true
+		default:
+			// The reader emptied the channel in the meantime.
+		}
+	}
+}
+
+// transientError marks a failure to read or decode a config, which may
+// be caused by reading a file while it is being written.
+type transientError struct {
+	error
+}
+
+func (e transientError) Unwrap() error {
+	return e.error
+}
+
+func isTransient(err error) bool {
+	var te transientError
+	return errors.As(err, &te)
+}
+
+// broadcastError must be called with b.mu held.
+func (b *ConfigLoader[Config]) broadcastError(err error) {
+	if b.quiet && isTransient(err) {
+		return
+	}
+	for _, s := range b.errSubs {
+		select {
+		case s <- err:
+		default:
+			b.logf("error subscriber channel is full")
+		}
+	}
+}
+
+// Reload re-reads the current config path. Subscribers are only notified
+// if the contents changed since the last successful load.
+func (b *ConfigLoader[Config]) Reload() error {
+	return b.Load("")
+}
+
+// Sync reloads the config like Reload, and delivers any broadcast held
+// back by WithMinBroadcastInterval right away, so that once it returns,
+// the config and subscribers reflect the files as they are, even if the
+// watcher has yet to handle their latest changes. It is meant for tests
+// and for shutdown sequences. Unlike Reload, it returns an error if the
+// loader is frozen.
+func (b *ConfigLoader[Config]) Sync() error {
+	return b.update(func() error {
+		if b.frozen {
+			return fmt.Errorf("config loader is frozen")
+		}
+		err := b.load()
+		b.releaseBroadcast()
+		return err
+	})
+}
+
+// watchReload reloads the config on behalf of the watcher, unless
+// watching is paused or the loader is frozen, and reports whether it did.
+// If retry is set, as for file events, a failure to read or decode the
+// config is retried a few times in case the file was caught halfway
+// through being written. Pausing or freezing the loader stops the retries.
+func (b *ConfigLoader[Config]) watchReload(src Source, retry bool) bool {
+	attempts := 1
+	if retry {
+		attempts = b.opts.retryAttempts
+	}
+	for i := 1; ; i++ {
+		final := i >= attempts
+		paused := false
+		err := b.update(func() error {
+			if b.paused || b.frozen {
+				paused = true
+				return nil
+			}
+			// Transient failures before the final attempt aren't
+			// reported, so that a retry that succeeds leaves no trace.
+			b.quiet = !final
+			defer func() { b.quiet = false }()
+			b.source = src
+			return b.load()
+		})
+		if paused {
+			// Earlier attempts did reload, if only to fail.
+			return i > 1
+		}
+		if final || !isTransient(err) {
+			return true
+		}
+		select {
+		case <-time.After(b.opts.retryBackoff):
+		case <-b.ctx.Done():
+			return true
+		}
+	}
+}
+
+// Pause stops the watcher from reloading the config, e.g. while a
+// deployment writes the config file in several steps. The current config
+// and subscriptions are unaffected, and Reload still works.
+func (b *ConfigLoader[Config]) Pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = true
+}
+
+// Resume undoes Pause and reloads the config once to catch up with any
+// changes made while paused.
+func (b *ConfigLoader[Config]) Resume() error {
+	b.mu.Lock()
+	b.paused = false
+	b.mu.Unlock()
+	return b.Load("")
+}
+
+// Freeze pins the current config until Unfreeze is called, e.g. to keep
+// a bad push from being applied during an incident. While frozen, file
+// changes and polls are ignored, including retries already under way, and
+// Load, Reload and TryLoad do nothing. Unlike Pause, which is meant for a
+// short deployment window, Freeze also stops explicit reloads.
+//
+// Deliberate changes of the config still take effect while frozen: those
+// made by SetConfigPath and the other methods that choose the config
+// source, WriteConfig, SetOverride, InjectConfig, ApplyBytes and
+// Rollback.
+func (b *ConfigLoader[Config]) Freeze() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.frozen = true
+}
+
+// Unfreeze undoes Freeze and reloads the config once to catch up with any
+// changes made while frozen.
+func (b *ConfigLoader[Config]) Unfreeze() error {
+	b.mu.Lock()
+	b.frozen = false
+	b.mu.Unlock()
+	return b.Load("")
+}
+
+// watch reloads the config as it changes until the loader is closed. w
+// is nil if changes must be polled for instead.
+func (b *ConfigLoader[Config]) watch(w *fsnotify.Watcher) {
+	defer close(b.stopped)
+	// Stopping because ctx is done is the same as being closed.
+	defer b.Close()
+	if b.sigs != nil {
+		defer signal.Stop(b.sigs)
+	}
+	(&watcher{
+		ctx:     b.ctx,
+		logf:    b.logf,
+		fsys:    b.opts.fsys != nil,
+		control: b.control,
+		sigs:    b.sigs,
+		targets: func() []watchTarget { return []watchTarget{b} },
+		jitter:  b.opts.pollJitter,
+	}).run(w)
+}
+
+// watchPaths returns every file the config may be read from; see
+// filePaths.
+func (b *ConfigLoader[Config]) watchPaths() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.filePaths()
+}
+
+// isWatchedPath reports whether name is one of the configured paths, or
+// matches the glob pattern.
+func (b *ConfigLoader[Config]) isWatchedPath(name string) bool {
+	name = filepath.Clean(name)
+	b.mu.Lock()
+	glob := b.glob
+	b.mu.Unlock()
+	if glob != "" {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	for _, path := range b.watchPaths() {
+		if filepath.Clean(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint returns the SHA-256 of the currently loaded config's raw
+// contents, or the value of the WithFingerprint function if one was set,
+// which identifies the config version without revealing it.
+func (b *ConfigLoader[Config]) Fingerprint() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fprint
+}
+
+// Stats describes the loader's reload history, e.g. for metrics.
+type Stats struct {
+	// ReloadCount is the number of times a new config was loaded.
+	ReloadCount int
+	// ErrorCount is the number of failed load attempts.
+	ErrorCount int
+	// LastSuccess is the time of the last successful load attempt,
+	// whether or not the config had changed.
+	LastSuccess time.Time
+	// LastError and LastErrorMessage describe the last failed load
+	// attempt. They are not cleared by later successes.
+	LastError        time.Time
+	LastErrorMessage string
+	// CurrentFingerprint is the fingerprint of the current config.
+	CurrentFingerprint string
+	// WatchMode is how the watcher notices changes: WatchModeFsnotify,
+	// WatchModePolling if fsnotify could not be set up and changes are
+	// only noticed by polling, or WatchModeNone if the config is read
+	// from an fs.FS or WithoutWatch was given, and it is only reloaded
+	// when asked to.
+	WatchMode string
+	// DroppedUpdates is the number of times a subscriber's channel was
+	// full, so that it missed a config or field value, or had a stale one
+	// replaced. A growing count points to a slow subscriber.
+	DroppedUpdates int
+}
+
+// Bounds of the backoff between attempts to watch a config directory
+// that doesn't exist.
+const (
+	minRewatchBackoff = 50 * time.Millisecond
+	maxRewatchBackoff = 5 * time.Second
+)
+
+// Watch modes reported in Stats.
+const (
+	WatchModeFsnotify = "fsnotify"
+	WatchModePolling  = "polling"
+	WatchModeNone     = "none"
+)
+
+// Stats returns a snapshot of the loader's reload statistics.
+func (b *ConfigLoader[Config]) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.CurrentFingerprint = b.fprint
+	return stats
+}
+
+// IsPolling reports whether the watcher fell back to polling because
+// fsnotify could not be set up, in which case changes take up to the poll
+// interval to be noticed.
+func (b *ConfigLoader[Config]) IsPolling() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats.WatchMode == WatchModePolling
+}
+
+// LastError returns the error from the most recent load attempt, or nil
+// if it succeeded.
+func (b *ConfigLoader[Config]) LastError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// ConfigSource returns where the config being served came from: the
+// config source itself, a previous config kept because the last load
+// failed, or a default. A readiness check can use it to tell whether the
+// program is running on fresh data.
+func (b *ConfigLoader[Config]) ConfigSource() ConfigOrigin {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.origin
+}
+
+// Config returns the current config, or nil if none has been loaded.
+func (b *ConfigLoader[Config]) Config() (conf *Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	conf = b.copyConfPtr(b.conf)
+	return
+}
+
+// CurrentConfig returns the current config and whether one has been
+// loaded. It never triggers a load or any other I/O, so it is safe to call
+// on hot paths.
+func (b *ConfigLoader[Config]) CurrentConfig() (*Config, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.copyConfPtr(b.conf), b.conf != nil
 }
@@ -1,7 +1,19 @@
 package configloader
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestConf struct {
@@ -48,3 +60,2216 @@ func TestSubscribeConfig(t *testing.T) {
 		t.Errorf("expected 'bar' = 'bar!', got %q", conf.Bar)
 	}
 }
+
+func writeConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", got)
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reload")
+	}
+}
+
+func TestInvalidPollInterval(t *testing.T) {
+	if _, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithPollInterval(-time.Second)); err == nil {
+		t.Errorf("expected an error for a negative poll interval")
+	}
+}
+
+func TestDisablePolling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	if loader.pollEvery() != 0 {
+		t.Errorf("expected polling to be disabled")
+	}
+
+	// Changes are still noticed through fsnotify.
+	ch := loader.Subscribe()
+	<-ch
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reload")
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	errs := loader.SubscribeErrors()
+	loader.RegisterCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "bad" {
+			return c, errors.New("foo must not be bad")
+		}
+		return c, nil
+	})
+
+	writeConfig(t, path, "foo: \"bad\"\nbar: \"bar!\"\n")
+	if err := loader.Reload(); err == nil {
+		t.Fatalf("expected reload to be rejected")
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("expected error to mention %q, got %v", path, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for error")
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
+
+func TestLastError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.LastError(); err != nil {
+		t.Fatalf("expected no error after initial load, got %v", err)
+	}
+
+	loader.RegisterCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "bad" {
+			return c, errors.New("foo must not be bad")
+		}
+		return c, nil
+	})
+
+	writeConfig(t, path, "foo: \"bad\"\nbar: \"bar!\"\n")
+	loader.Reload()
+	if err := loader.LastError(); err == nil {
+		t.Errorf("expected an error after a rejected reload")
+	}
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	loader.Reload()
+	if err := loader.LastError(); err != nil {
+		t.Errorf("expected error to be cleared, got %v", err)
+	}
+}
+
+func TestSetConfigPaths(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	overlay := filepath.Join(t.TempDir(), "overlay.yaml")
+	writeConfig(t, base, "foo: \"base\"\nbar: \"base\"\n")
+	writeConfig(t, overlay, "bar: \"overlay\"\n")
+
+	loader, err := NewConfigLoader[TestConf](base, WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigPaths([]string{base, overlay}, true); err != nil {
+		t.Fatalf("error setting config paths: %v", err)
+	}
+	conf := loader.Config()
+	if conf.Foo != "base" {
+		t.Errorf("expected 'foo' = 'base', got %q", conf.Foo)
+	}
+	if conf.Bar != "overlay" {
+		t.Errorf("expected 'bar' = 'overlay', got %q", conf.Bar)
+	}
+
+	ch := loader.Subscribe()
+	<-ch
+	writeConfig(t, overlay, "foo: \"overlay\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "overlay" || conf.Bar != "base" {
+			t.Errorf("expected re-merged config, got %+v", conf)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for re-merge")
+	}
+}
+
+func TestSetConfigPathsOptional(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.yaml")
+	writeConfig(t, base, "foo: \"base\"\nbar: \"base\"\n")
+
+	loader, err := NewConfigLoader[TestConf](base)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := loader.SetConfigPaths([]string{base, missing}, true); err == nil {
+		t.Errorf("expected an error for a missing required file")
+	}
+	if err := loader.SetConfigPaths([]string{base, missing}, false); err != nil {
+		t.Errorf("expected missing optional file to be skipped, got %v", err)
+	}
+}
+
+func TestAtomicReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	tmp := filepath.Join(dir, "config.yaml.tmp")
+	writeConfig(t, tmp, "foo: \"two\"\nbar: \"bar!\"\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("error renaming config: %v", err)
+	}
+
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reload after rename")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	loader, err := NewConfigLoaderContext[TestConf](ctx, "testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	cancel()
+	select {
+	case <-loader.stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("watcher did not exit after context cancellation")
+	}
+
+	// Close is still valid after the context is done, and setting a new
+	// path must not block on the stopped watcher.
+	loader.Close()
+	done := make(chan struct{})
+	go func() {
+		loader.SetConfigPath("testdata/config.json")
+		loader.SetConfigPath("testdata/config.yaml")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("SetConfigPath blocked after cancellation")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	loader.Close()
+	loader.Close()
+
+	if err := loader.SetConfigPath("testdata/config.json"); err != nil {
+		t.Errorf("error setting config path after close: %v", err)
+	}
+}
+
+func TestSubscribeChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.SubscribeChanges()
+	first := <-ch
+	if first.Old != nil {
+		t.Errorf("expected no old config on first delivery, got %+v", *first.Old)
+	}
+	if first.New.Foo != "one" {
+		t.Errorf("expected new 'foo' = 'one', got %q", first.New.Foo)
+	}
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	loader.Reload()
+	select {
+	case change := <-ch:
+		if change.Old == nil || change.Old.Foo != "one" {
+			t.Errorf("expected old 'foo' = 'one', got %+v", change.Old)
+		}
+		if change.New.Foo != "two" {
+			t.Errorf("expected new 'foo' = 'two', got %q", change.New.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for change")
+	}
+}
+
+func TestWatchNotYetCreated(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := loader.SetConfigPaths([]string{path}, false); err != nil {
+		t.Fatalf("error setting optional config path: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Foo != "" {
+			t.Errorf("expected the zero config for a missing file, got %+v", conf)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the zero config")
+	}
+
+	writeConfig(t, path, "foo: \"created\"\nbar: \"bar!\"\n")
+
+	select {
+	case conf := <-ch:
+		if conf.Foo != "created" {
+			t.Errorf("expected 'foo' = 'created', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the created config")
+	}
+}
+
+func TestSetConfigReader(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"reader\"\n"), true); err != nil {
+		t.Fatalf("error loading config from reader: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Foo != "reader" {
+			t.Errorf("expected 'foo' = 'reader', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reader config")
+	}
+
+	if err := loader.SetConfigReader(nil, true); err == nil {
+		t.Errorf("expected an error for a nil required reader")
+	}
+}
+
+func TestCallbackChain(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	loader.AddCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "" {
+			c.Foo = "default"
+		}
+		return c, nil
+	})
+	loader.AddCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "" {
+			return c, errors.New("foo is required")
+		}
+		c.Foo = strings.ToUpper(c.Foo)
+		return c, nil
+	})
+
+	if err := loader.SetConfigReader(strings.NewReader("bar: \"bar!\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "DEFAULT" {
+		t.Errorf("expected 'foo' = 'DEFAULT', got %q", got)
+	}
+}
+
+func TestRawCallback(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	loader.RegisterRawCallback(func(data []byte) error {
+		if !bytes.HasPrefix(data, []byte("# reviewed\n")) {
+			return errors.New("missing review header")
+		}
+		return nil
+	})
+
+	if err := loader.SetConfigReader(strings.NewReader("# reviewed\nfoo: \"one\"\nbar: \"bar!\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	err = loader.SetConfigReader(strings.NewReader("foo: \"two\"\nbar: \"bar!\"\n"), true)
+	if err == nil || !strings.Contains(err.Error(), "missing review header") {
+		t.Errorf("expected the raw callback to reject the config, got %v", err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+
+	loader.ClearCallbacks()
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"two\"\nbar: \"bar!\"\n"), true); err != nil {
+		t.Errorf("expected no raw callbacks after ClearCallbacks, got %v", err)
+	}
+}
+
+func TestRemoveCallback(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	reject := loader.AddCallback(func(c TestConf) (TestConf, error) {
+		return c, errors.New("rejected")
+	})
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"one\"\n"), true); err == nil {
+		t.Fatalf("expected config to be rejected")
+	}
+
+	if !loader.RemoveCallback(reject) {
+		t.Errorf("expected callback to be removed")
+	}
+	if loader.RemoveCallback(reject) {
+		t.Errorf("expected second removal to report false")
+	}
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+
+	loader.AddCallback(func(c TestConf) (TestConf, error) {
+		return c, errors.New("rejected")
+	})
+	loader.ClearCallbacks()
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"two\"\n"), true); err != nil {
+		t.Errorf("expected no callbacks after clear, got %v", err)
+	}
+}
+
+func TestTransitionCallback(t *testing.T) {
+	type limits struct {
+		MaxConns int `yaml:"maxConns"`
+	}
+	loader, err := NewConfigLoader[limits]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	var sawNil bool
+	loader.AddTransitionCallback(func(old *limits, c limits) (limits, error) {
+		if old == nil {
+			sawNil = true
+			return c, nil
+		}
+		if c.MaxConns > 2*old.MaxConns {
+			return c, fmt.Errorf("maxConns grew from %d to %d", old.MaxConns, c.MaxConns)
+		}
+		return c, nil
+	})
+
+	if err := loader.SetConfigReader(strings.NewReader("maxConns: 100\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if !sawNil {
+		t.Errorf("expected old config to be nil on first load")
+	}
+	if err := loader.SetConfigReader(strings.NewReader("maxConns: 150\n"), true); err != nil {
+		t.Fatalf("expected doubling rule to allow 150: %v", err)
+	}
+	if err := loader.SetConfigReader(strings.NewReader("maxConns: 1000\n"), true); err == nil {
+		t.Errorf("expected doubling rule to reject 1000")
+	}
+	if got := loader.Config().MaxConns; got != 150 {
+		t.Errorf("expected previous config to be kept, got maxConns %d", got)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	data, err := os.ReadFile("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256(data))
+	if got := loader.Fingerprint(); got != want {
+		t.Errorf("expected fingerprint %s, got %s", want, got)
+	}
+}
+
+func TestCustomFingerprint(t *testing.T) {
+	// Ignore comment lines, so that editing them doesn't cause a reload.
+	stripComments := func(data []byte) string {
+		var kept []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+				kept = append(kept, line)
+			}
+		}
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(kept, "\n"))))
+	}
+
+	loader, err := NewConfigLoader[TestConf]("", WithFingerprint(stripComments))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader("# one\nfoo: \"foo\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if err := loader.SetConfigReader(strings.NewReader("# two\nfoo: \"foo\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if n := loader.Stats().ReloadCount; n != 1 {
+		t.Errorf("expected a comment-only edit not to reload, got %d reloads", n)
+	}
+	if err := loader.SetConfigReader(strings.NewReader("# two\nfoo: \"bar\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if n := loader.Stats().ReloadCount; n != 2 {
+		t.Errorf("expected a value edit to reload, got %d reloads", n)
+	}
+}
+
+func TestSemanticChangeDetection(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("", WithSemanticChangeDetection(true))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"one\"\nbar: \"two\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	<-ch
+
+	// Reordered keys decode to the same config.
+	if err := loader.SetConfigReader(strings.NewReader("bar: \"two\"\nfoo: \"one\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no broadcast for reordered keys, got %+v", conf)
+	default:
+	}
+	if n := loader.Stats().ReloadCount; n != 1 {
+		t.Errorf("expected 1 reload, got %d", n)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("bar: \"three\"\nfoo: \"one\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Bar != "three" {
+			t.Errorf("expected 'bar' = 'three', got %q", conf.Bar)
+		}
+	default:
+		t.Errorf("expected a broadcast for a changed value")
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatalf("error setting mode: %v", err)
+	}
+
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := loader.WriteConfig(TestConf{Foo: "written", Bar: "bar!"}); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+
+	select {
+	case conf := <-ch:
+		if conf.Foo != "written" {
+			t.Errorf("expected 'foo' = 'written', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for written config")
+	}
+
+	// The file event for the write must not cause a second broadcast.
+	select {
+	case conf := <-ch:
+		t.Errorf("unexpected second broadcast: %+v", conf)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error statting config: %v", err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600 to be preserved, got %v", fi.Mode().Perm())
+	}
+}
+
+func TestSubscribeBlocking(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	const n = 20
+	ch := loader.SubscribeBlocking()
+	go func() {
+		for i := 0; i < n; i++ {
+			loader.SetConfigReader(strings.NewReader(fmt.Sprintf("foo: \"%d\"\n", i)), true)
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		select {
+		case conf := <-ch:
+			if want := fmt.Sprint(i); conf.Foo != want {
+				t.Fatalf("expected 'foo' = %q, got %q", want, conf.Foo)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for update %d", i)
+		}
+	}
+}
+
+func TestSubscribeBuffer(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithSubscribeBuffer(4))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if got := cap(loader.Subscribe()); got != 4 {
+		t.Errorf("expected a buffer of 4, got %d", got)
+	}
+	if _, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithSubscribeBuffer(0)); err == nil {
+		t.Errorf("expected an error for a zero buffer")
+	}
+}
+
+func TestSlowSubscriberGetsLatest(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	loader.SetConfigReader(strings.NewReader("foo: \"first\"\n"), true)
+	ch := loader.Subscribe()
+	for _, foo := range []string{"second", "third", "fourth"} {
+		loader.SetConfigReader(strings.NewReader(fmt.Sprintf("foo: %q\n", foo)), true)
+	}
+
+	if got := (<-ch).Foo; got != "fourth" {
+		t.Errorf("expected the latest config 'fourth', got %q", got)
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no further configs, got %+v", conf)
+	default:
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	seen := make(chan string, 10)
+	loader.OnChange(func(c TestConf) {
+		seen <- c.Foo
+		if c.Foo == "panic" {
+			panic("boom")
+		}
+	})
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-seen:
+			if got != want {
+				t.Errorf("expected 'foo' = %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	expect("foo!")
+	loader.SetConfigReader(strings.NewReader("foo: \"panic\"\n"), true)
+	expect("panic")
+	loader.SetConfigReader(strings.NewReader("foo: \"after\"\n"), true)
+	expect("after")
+}
+
+func TestStats(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	loader.AddCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "bad" {
+			return c, errors.New("foo must not be bad")
+		}
+		return c, nil
+	})
+
+	start := time.Now()
+	loader.SetConfigReader(strings.NewReader("foo: \"one\"\n"), true)
+	loader.SetConfigReader(strings.NewReader("foo: \"two\"\n"), true)
+	loader.SetConfigReader(strings.NewReader("foo: \"bad\"\n"), true)
+
+	stats := loader.Stats()
+	if stats.ReloadCount != 2 {
+		t.Errorf("expected 2 reloads, got %d", stats.ReloadCount)
+	}
+	if stats.ErrorCount < 1 {
+		t.Errorf("expected at least 1 error, got %d", stats.ErrorCount)
+	}
+	if stats.LastSuccess.Before(start) {
+		t.Errorf("expected LastSuccess to be set, got %v", stats.LastSuccess)
+	}
+	if stats.LastError.Before(stats.LastSuccess) {
+		t.Errorf("expected LastError %v after LastSuccess %v", stats.LastError, stats.LastSuccess)
+	}
+	if !strings.Contains(stats.LastErrorMessage, "foo must not be bad") {
+		t.Errorf("expected LastErrorMessage to describe the rejection, got %q", stats.LastErrorMessage)
+	}
+	if stats.CurrentFingerprint != loader.Fingerprint() {
+		t.Errorf("expected CurrentFingerprint %q, got %q", loader.Fingerprint(), stats.CurrentFingerprint)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"v0\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	loader.Pause()
+	for i := 1; i <= 3; i++ {
+		writeConfig(t, path, fmt.Sprintf("foo: \"v%d\"\nbar: \"bar!\"\n", i))
+		time.Sleep(50 * time.Millisecond)
+	}
+	select {
+	case conf := <-ch:
+		t.Fatalf("unexpected broadcast while paused: %+v", conf)
+	default:
+	}
+
+	if err := loader.Resume(); err != nil {
+		t.Fatalf("error resuming: %v", err)
+	}
+	if got := (<-ch).Foo; got != "v3" {
+		t.Errorf("expected 'foo' = 'v3', got %q", got)
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected exactly one broadcast, got another: %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"v0\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	loader.Freeze()
+	writeConfig(t, path, "foo: \"v1\"\nbar: \"bar!\"\n")
+	time.Sleep(50 * time.Millisecond)
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading while frozen: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		t.Fatalf("unexpected broadcast while frozen: %+v", conf)
+	default:
+	}
+	if got := loader.Config().Foo; got != "v0" {
+		t.Errorf("expected frozen 'foo' = 'v0', got %q", got)
+	}
+
+	if err := loader.Unfreeze(); err != nil {
+		t.Fatalf("error unfreezing: %v", err)
+	}
+	if got := (<-ch).Foo; got != "v1" {
+		t.Errorf("expected 'foo' = 'v1', got %q", got)
+	}
+}
+
+func TestFreezeStopsRetries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path,
+		WithPollInterval(time.Hour), WithRetry(10, 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	// Freeze while the truncated write is being retried; the retry must
+	// not apply the completed file.
+	writeConfig(t, path, "foo: \"two\"\nbar: \"ba")
+	time.Sleep(20 * time.Millisecond)
+	loader.Freeze()
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		t.Errorf("unexpected broadcast while frozen: %+v", conf)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected frozen 'foo' = 'one', got %q", got)
+	}
+}
+
+func TestCallbackPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	loader.RegisterCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "panic" {
+			var m map[string]string
+			m["boom"] = "boom"
+		}
+		return c, nil
+	})
+
+	ch := loader.Subscribe()
+	<-ch
+
+	writeConfig(t, path, "foo: \"panic\"\nbar: \"bar!\"\n")
+	time.Sleep(100 * time.Millisecond)
+	if err := loader.LastError(); err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected LastError to report the panic, got %v", err)
+	}
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("watcher did not survive the panicking callback")
+	}
+}
+
+func TestRetryTruncatedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path,
+		WithPollInterval(time.Hour), WithRetry(10, 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+	errs := loader.SubscribeErrors()
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"ba")
+	time.Sleep(20 * time.Millisecond)
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for the complete config")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("expected the truncated read to be retried silently, got %v", err)
+	default:
+	}
+	if err := loader.LastError(); err != nil {
+		t.Errorf("expected no LastError, got %v", err)
+	}
+}
+
+func TestCurrentConfig(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if conf, ok := loader.CurrentConfig(); ok || conf != nil {
+		t.Errorf("expected no config before loading, got %+v", conf)
+	}
+
+	loader.SetConfigPath("testdata/config.yaml")
+	conf, ok := loader.CurrentConfig()
+	if !ok {
+		t.Fatalf("expected a config after loading")
+	}
+	if conf.Foo != "foo!" {
+		t.Errorf("expected 'foo' = 'foo!', got %q", conf.Foo)
+	}
+}
+
+func TestWatchMode(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if mode := loader.Stats().WatchMode; mode != WatchModeFsnotify {
+		t.Errorf("expected watch mode %q, got %q", WatchModeFsnotify, mode)
+	}
+	if loader.IsPolling() {
+		t.Errorf("expected not to be polling")
+	}
+}
+
+func TestConfigDirRecreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "conf.d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("error creating config dir: %v", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	// Poll rarely, so that only the re-established watch can notice.
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("error removing config dir: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("error recreating config dir: %v", err)
+	}
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for config from recreated dir")
+	}
+}
+
+func TestWaitForConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	loader, _ := NewConfigLoader[TestConf](path)
+	if loader == nil {
+		t.Fatalf("error creating config loader")
+	}
+	defer loader.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		writeConfig(t, path, "foo: \"late\"\nbar: \"bar!\"\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conf, err := loader.WaitForConfig(ctx)
+	if err != nil {
+		t.Fatalf("error waiting for config: %v", err)
+	}
+	if conf.Foo != "late" {
+		t.Errorf("expected 'foo' = 'late', got %q", conf.Foo)
+	}
+
+	// Once loaded, it returns immediately.
+	if conf, err := loader.WaitForConfig(ctx); err != nil || conf.Foo != "late" {
+		t.Errorf("expected current config, got %+v, %v", conf, err)
+	}
+}
+
+func TestWaitForConfigCancel(t *testing.T) {
+	loader, _ := NewConfigLoader[TestConf](filepath.Join(t.TempDir(), "config.yaml"))
+	if loader == nil {
+		t.Fatalf("error creating config loader")
+	}
+	defer loader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := loader.WaitForConfig(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	loader.mu.Lock()
+	n := len(loader.subs)
+	loader.mu.Unlock()
+	if n != 0 {
+		t.Errorf("expected internal subscription to be removed, got %d subscribers", n)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	loader, err := NewWithOptions[TestConf](
+		WithPath("testdata/config.yaml"),
+		WithLogger(log.New(&buf, "", 0)),
+	)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if got := loader.Config().Foo; got != "foo!" {
+		t.Errorf("expected 'foo' = 'foo!', got %q", got)
+	}
+	if !strings.Contains(buf.String(), "read config") {
+		t.Errorf("expected log output to go to the logger, got %q", buf.String())
+	}
+}
+
+func TestConflictingOptions(t *testing.T) {
+	if _, err := NewWithOptions[TestConf](WithDecoder(YAMLDecoder{}), WithDecoder(TOMLDecoder{})); err == nil {
+		t.Errorf("expected two decoders to conflict")
+	}
+	if _, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithPath("testdata/config.json")); err == nil {
+		t.Errorf("expected path and WithPath to conflict")
+	}
+	if _, err := NewWithOptions[TestConf](WithStrict(true), WithStrict(false)); err == nil {
+		t.Errorf("expected two WithStrict options to conflict")
+	}
+	if _, err := NewWithOptions[TestConf](WithDeepCopy(true), WithCloneFunc(func(c TestConf) TestConf { return c })); err == nil {
+		t.Errorf("expected WithDeepCopy and WithCloneFunc to conflict")
+	}
+}
+
+func TestSubscribeWithPolicy(t *testing.T) {
+	newLoader := func(t *testing.T) (*ConfigLoader[TestConf], func(string) error) {
+		loader, err := NewConfigLoader[TestConf]("")
+		if loader == nil {
+			t.Fatalf("error creating config loader: %v", err)
+		}
+		t.Cleanup(loader.Close)
+		load := func(foo string) error {
+			return loader.SetConfigReader(strings.NewReader(fmt.Sprintf("foo: %q\nbar: \"bar!\"\n", foo)), true)
+		}
+		if err := load("v0"); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+		return loader, load
+	}
+	expect := func(t *testing.T, ch chan TestConf, want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case conf := <-ch:
+				if conf.Foo != w {
+					t.Errorf("expected 'foo' = %q, got %q", w, conf.Foo)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for %q", w)
+			}
+		}
+		select {
+		case conf := <-ch:
+			t.Errorf("expected no more configs, got %q", conf.Foo)
+		default:
+		}
+	}
+
+	t.Run("DropOldest", func(t *testing.T) {
+		loader, load := newLoader(t)
+		ch := loader.SubscribeWith(DropOldest)
+		for _, v := range []string{"v1", "v2", "v3"} {
+			if err := load(v); err != nil {
+				t.Fatalf("error loading config: %v", err)
+			}
+		}
+		expect(t, ch, "v3")
+		if got := loader.Stats().DroppedUpdates; got != 3 {
+			t.Errorf("expected 3 dropped updates, got %d", got)
+		}
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		loader, load := newLoader(t)
+		ch := loader.SubscribeWith(DropNewest)
+		for _, v := range []string{"v1", "v2", "v3"} {
+			if err := load(v); err != nil {
+				t.Fatalf("error loading config: %v", err)
+			}
+		}
+		expect(t, ch, "v0")
+		if got := loader.Stats().DroppedUpdates; got != 3 {
+			t.Errorf("expected 3 dropped updates, got %d", got)
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		loader, load := newLoader(t)
+		ch := loader.SubscribeWith(Block)
+		done := make(chan error, 1)
+		go func() {
+			for _, v := range []string{"v1", "v2"} {
+				if err := load(v); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+		select {
+		case err := <-done:
+			t.Fatalf("expected loading to wait for the stalled reader, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		for _, w := range []string{"v0", "v1", "v2"} {
+			if got := (<-ch).Foo; got != w {
+				t.Errorf("expected 'foo' = %q, got %q", w, got)
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+		if got := loader.Stats().DroppedUpdates; got != 0 {
+			t.Errorf("expected no dropped updates, got %d", got)
+		}
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if got := loader.SubscriberCount(); got != 0 {
+		t.Fatalf("expected no subscribers, got %d", got)
+	}
+	ch := loader.Subscribe()
+	keep := loader.SubscribeWith(DropNewest)
+	block := loader.SubscribeBlocking()
+	ack, _ := loader.SubscribeAck()
+	if got := loader.SubscriberCount(); got != 4 {
+		t.Errorf("expected 4 subscribers, got %d", got)
+	}
+
+	for _, c := range []chan TestConf{ch, keep, block} {
+		if !loader.Unsubscribe(c) {
+			t.Errorf("expected the channel to be subscribed")
+		}
+	}
+	// The replay to ack is waiting to be received.
+	if !loader.Unsubscribe(ack) {
+		t.Errorf("expected the ack channel to be subscribed")
+	}
+	if got := loader.SubscriberCount(); got != 0 {
+		t.Errorf("expected no subscribers, got %d", got)
+	}
+	if loader.Unsubscribe(ch) {
+		t.Errorf("expected a second Unsubscribe to report false")
+	}
+	for _, c := range []chan TestConf{ch, keep, block, ack} {
+		for range c {
+		}
+	}
+
+	// A blocking subscriber with a full channel can unsubscribe while an
+	// update waits on it.
+	block = loader.SubscribeBlocking()
+	done := make(chan error, 1)
+	go func() {
+		done <- loader.SetConfigReader(strings.NewReader("foo: \"stalled\"\n"), true)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	loader.Unsubscribe(block)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("error loading config: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the update to abandon the unsubscribed channel")
+	}
+}
+
+func TestSubscribeContext(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := loader.SubscribeContext(ctx)
+	if conf := <-ch; conf.Foo != "foo!" {
+		t.Errorf("expected the current config, got %+v", conf)
+	}
+	if got := loader.SubscriberCount(); got != 1 {
+		t.Errorf("expected 1 subscriber, got %d", got)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the channel to close")
+	}
+	if got := loader.SubscriberCount(); got != 0 {
+		t.Errorf("expected no subscribers, got %d", got)
+	}
+}
+
+func TestSubscribeWithReplay(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"one\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	replayed := loader.SubscribeWithReplay(true)
+	updates := loader.SubscribeWithReplay(false)
+
+	select {
+	case conf := <-replayed:
+		if conf.Foo != "one" {
+			t.Errorf("expected replay of 'foo' = 'one', got %q", conf.Foo)
+		}
+	default:
+		t.Errorf("expected the current config to be replayed")
+	}
+	select {
+	case conf := <-updates:
+		t.Errorf("expected no replay, got %+v", conf)
+	default:
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"two\"\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	for name, ch := range map[string]chan TestConf{"replayed": replayed, "updates": updates} {
+		select {
+		case conf := <-ch:
+			if conf.Foo != "two" {
+				t.Errorf("%s: expected 'foo' = 'two', got %q", name, conf.Foo)
+			}
+		default:
+			t.Errorf("%s: expected the new config", name)
+		}
+		select {
+		case conf := <-ch:
+			t.Errorf("%s: expected a single delivery, got %+v", name, conf)
+		default:
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	want, err := filepath.Abs("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error resolving path: %v", err)
+	}
+	if path, required := loader.ConfigPath(); path != want || !required {
+		t.Errorf("expected (%q, true), got (%q, %v)", want, path, required)
+	}
+
+	if err := loader.SetConfigPaths([]string{"testdata/config.yaml", "testdata/missing.yaml"}, false); err != nil {
+		t.Fatalf("error setting config paths: %v", err)
+	}
+	paths, required := loader.ConfigPaths()
+	if len(paths) != 2 || paths[0] != want || !filepath.IsAbs(paths[1]) || required {
+		t.Errorf("expected two absolute optional paths, got %q, %v", paths, required)
+	}
+}
+
+func TestSetConfigPathStress(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")}
+	writeConfig(t, paths[0], "foo: \"a\"\nbar: \"bar!\"\n")
+	writeConfig(t, paths[1], "foo: \"b\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](paths[0])
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	// A blocking subscriber sees every broadcast, so a duplicate shows up
+	// as the same config twice in a row.
+	ch := loader.SubscribeBlocking()
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for conf := range ch {
+			got = append(got, conf.Foo)
+			if conf.Foo == "end" {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := loader.SetConfigPath(paths[(g+i)%2]); err != nil {
+					t.Errorf("error setting config path: %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	end := filepath.Join(dir, "end.yaml")
+	writeConfig(t, end, "foo: \"end\"\nbar: \"bar!\"\n")
+	if err := loader.SetConfigPath(end); err != nil {
+		t.Fatalf("error setting config path: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for deliveries")
+	}
+
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Fatalf("config %q delivered twice in a row at %d", got[i], i)
+		}
+	}
+	if n := loader.Stats().ReloadCount; n != len(got) {
+		t.Errorf("expected one delivery per reload, got %d deliveries for %d reloads", len(got), n)
+	}
+}
+
+func TestSetConfigPathThenWriteStress(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		dir := t.TempDir()
+		first := filepath.Join(dir, "first.yaml")
+		writeConfig(t, first, "foo: \"first\"\nbar: \"bar!\"\n")
+		loader, err := NewConfigLoader[TestConf](first, WithPollInterval(0))
+		if err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+
+		// Switch to a file in another directory straight away, and change
+		// it as soon as SetConfigPath returns: the watcher must already be
+		// watching its directory.
+		other := filepath.Join(t.TempDir(), "second.yaml")
+		writeConfig(t, other, "foo: \"second\"\nbar: \"bar!\"\n")
+		if err := loader.SetConfigPath(other); err != nil {
+			t.Fatalf("error setting config path: %v", err)
+		}
+		ch := loader.Subscribe()
+		<-ch
+		writeConfig(t, other, "foo: \"changed\"\nbar: \"bar!\"\n")
+		select {
+		case conf := <-ch:
+			if conf.Foo != "changed" {
+				t.Errorf("expected 'foo' = 'changed', got %q", conf.Foo)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("iteration %d: change right after SetConfigPath was missed", i)
+		}
+		loader.Close()
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithMaxSize(64))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	big := filepath.Join(dir, "big.yaml")
+	writeConfig(t, big, "foo: \""+strings.Repeat("x", 100)+"\"\n")
+	if err := loader.SetConfigPath(big); err == nil || !strings.Contains(err.Error(), "maximum") {
+		t.Errorf("expected an oversized config to be rejected, got %v", err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+
+	// The default limit applies too. A sparse file is cheap to create.
+	huge := filepath.Join(dir, "huge.yaml")
+	writeConfig(t, huge, "foo: \"huge\"\n")
+	if err := os.Truncate(huge, 11<<20); err != nil {
+		t.Fatalf("error growing config: %v", err)
+	}
+	other, err := NewConfigLoader[TestConf](huge)
+	if err == nil {
+		t.Errorf("expected a config over the default maximum to be rejected")
+	}
+	if other != nil {
+		other.Close()
+	}
+}
+
+func TestRequiredMissingHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	fallback := func() (TestConf, error) {
+		return TestConf{Foo: "fallback"}, nil
+	}
+	loader, err := NewConfigLoader[TestConf](path, WithRequiredMissingHandler(fallback))
+	if err != nil {
+		t.Fatalf("expected the handler to replace the error, got %v", err)
+	}
+	defer loader.Close()
+	if got := loader.Config().Foo; got != "fallback" {
+		t.Errorf("expected 'foo' = 'fallback', got %q", got)
+	}
+
+	failing := func() (TestConf, error) {
+		return TestConf{}, errors.New("no fallback")
+	}
+	other, err := NewConfigLoader[TestConf](path, WithRequiredMissingHandler(failing))
+	if err == nil {
+		t.Errorf("expected the missing config error when the handler fails")
+	}
+	if other != nil {
+		other.Close()
+	}
+
+	if _, err := NewConfigLoader[TestConf](path, WithRequiredMissingHandler(func() (string, error) { return "", nil })); err == nil {
+		t.Errorf("expected a handler of the wrong type to be rejected")
+	}
+}
+
+func TestChangeSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.SubscribeChanges()
+	<-ch
+
+	expect := func(want Source, foo string) {
+		t.Helper()
+		select {
+		case chg := <-ch:
+			if chg.New.Foo != foo || chg.Source != want {
+				t.Errorf("expected %q from %v, got %q from %v", foo, want, chg.New.Foo, chg.Source)
+			}
+			if time.Since(chg.Time) > time.Minute {
+				t.Errorf("expected a recent change time, got %v", chg.Time)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q from %v", foo, want)
+		}
+	}
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	expect(SourceFsnotify, "two")
+
+	other := filepath.Join(dir, "other.yaml")
+	writeConfig(t, other, "foo: \"three\"\nbar: \"bar!\"\n")
+	if err := loader.SetConfigPath(other); err != nil {
+		t.Fatalf("error setting config path: %v", err)
+	}
+	expect(SourcePathChange, "three")
+
+	if err := loader.InjectConfig(TestConf{Foo: "four"}); err != nil {
+		t.Fatalf("error injecting config: %v", err)
+	}
+	expect(SourceManual, "four")
+}
+
+func TestCloseClosesSubscribers(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	drain := func(ch chan TestConf) {
+		defer wg.Done()
+		for range ch {
+		}
+	}
+	wg.Add(2)
+	go drain(loader.Subscribe())
+	go drain(loader.SubscribeBlocking())
+	errs := loader.SubscribeErrors()
+	changes := loader.SubscribeChanges()
+
+	loader.Close()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for subscribers to exit")
+	}
+	if _, ok := <-errs; ok {
+		t.Errorf("expected error channel to be closed")
+	}
+	for range changes {
+	}
+
+	// Loads after closing don't send on the closed channels.
+	if err := loader.Reload(); err != nil {
+		t.Errorf("error reloading config: %v", err)
+	}
+	if _, ok := <-loader.Subscribe(); ok {
+		t.Errorf("expected subscribing after close to return a closed channel")
+	}
+}
+
+func TestDone(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	select {
+	case <-loader.Done():
+		t.Fatalf("expected Done to be open before Close")
+	default:
+	}
+	loader.Close()
+	loader.Close()
+	select {
+	case <-loader.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected Done to be closed after Close")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loader, err = NewConfigLoaderContext[TestConf](ctx, "testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	cancel()
+	select {
+	case <-loader.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected Done to be closed after the context is cancelled")
+	}
+}
+
+func TestDroppedUpdates(t *testing.T) {
+	var buf bytes.Buffer
+	loader, err := NewConfigLoader[TestConf]("", WithLogger(log.New(&buf, "", 0)))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	// Never read, so the buffer of 1 fills on the first load.
+	loader.Subscribe()
+	for i, foo := range []string{"one", "two", "three"} {
+		if err := loader.SetConfigReader(strings.NewReader("foo: \""+foo+"\"\n"), true); err != nil {
+			t.Fatalf("error loading config %d: %v", i, err)
+		}
+	}
+	if n := loader.Stats().DroppedUpdates; n != 2 {
+		t.Errorf("expected 2 dropped updates, got %d", n)
+	}
+	if !strings.Contains(buf.String(), "subscriber channel is full") {
+		t.Errorf("expected drops to be logged to the logger, got %q", buf.String())
+	}
+}
+
+func TestChmodIsNotAChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+	reloads := loader.Stats().ReloadCount
+
+	for _, mode := range []os.FileMode{0o600, 0o644} {
+		before := loader.Stats().LastSuccess
+		writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("error changing mode: %v", err)
+		}
+		// The chmod is acted on, but the unchanged contents are not.
+		deadline := time.Now().Add(time.Second)
+		for !loader.Stats().LastSuccess.After(before) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for the chmod to be noticed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no broadcast for a chmod, got %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := loader.Stats().ReloadCount; got != reloads {
+		t.Errorf("expected ReloadCount to stay %d, got %d", reloads, got)
+	}
+}
+
+func TestWithoutWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	before := runtime.NumGoroutine()
+	loader, err := NewConfigLoader[TestConf](path, WithoutWatch())
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected no goroutines to be started, went from %d to %d", before, n)
+	}
+	if got := loader.Stats().WatchMode; got != WatchModeNone {
+		t.Errorf("expected WatchMode %q, got %q", WatchModeNone, got)
+	}
+
+	ch := loader.Subscribe()
+	if got := (<-ch).Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no live updates, got %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", got)
+	}
+
+	loader.Close()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected no goroutines to be left, went from %d to %d", before, n)
+	}
+
+	if _, err := NewConfigLoader[TestConf](path, WithoutWatch(), WithReloadSignal(os.Interrupt)); err == nil {
+		t.Errorf("expected WithoutWatch to conflict with WithReloadSignal")
+	}
+}
+
+func TestConfigSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	loader, err := NewConfigLoader[TestConf]("", WithPollInterval(0))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	expect := func(want ConfigOrigin) {
+		t.Helper()
+		if got := loader.ConfigSource(); got != want {
+			t.Errorf("expected config source %v, got %v", want, got)
+		}
+	}
+	expect(Default)
+
+	if err := loader.SetConfigPaths([]string{path}, false); err != nil {
+		t.Fatalf("error setting config path: %v", err)
+	}
+	expect(Default)
+
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	expect(FromFile)
+
+	writeConfig(t, path, "foo: [not a string\n")
+	if err := loader.Reload(); err == nil {
+		t.Fatalf("expected an error for invalid YAML")
+	}
+	expect(PreviousCached)
+
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	expect(FromFile)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("error removing config: %v", err)
+	}
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	expect(Default)
+}
+
+func TestSetConfigPathWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "config.local.yaml")
+	fallback := filepath.Join(dir, "config.yaml")
+	writeConfig(t, primary, "foo: \"local\"\nbar: \"bar!\"\n")
+	writeConfig(t, fallback, "foo: \"baseline\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf]("", WithPollInterval(0))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	if err := loader.SetConfigPathWithFallback(primary, fallback, true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	ch := loader.Subscribe()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case conf := <-ch:
+			if conf.Foo != want {
+				t.Errorf("expected 'foo' = %q, got %q", want, conf.Foo)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	expect("local")
+
+	if err := os.Remove(primary); err != nil {
+		t.Fatalf("error removing config: %v", err)
+	}
+	expect("baseline")
+
+	writeConfig(t, primary, "foo: \"local\"\nbar: \"bar!\"\n")
+	expect("local")
+
+	writeConfig(t, fallback, "foo: \"new baseline\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		t.Errorf("expected a fallback change to be ignored while the primary exists, got %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSetConfigGlob(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "conf.d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("error creating directory: %v", err)
+	}
+	writeConfig(t, filepath.Join(dir, "10-base.yaml"), "foo: \"base\"\nbar: \"bar!\"\n")
+	writeConfig(t, filepath.Join(dir, "README"), "foo: \"not a fragment\"\n")
+
+	loader, err := NewConfigLoader[TestConf]("", WithPollInterval(0))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	if err := loader.SetConfigGlob(filepath.Join(dir, "*.yaml"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	ch := loader.Subscribe()
+	expect := func(foo, bar string) {
+		t.Helper()
+		select {
+		case conf := <-ch:
+			if conf.Foo != foo || conf.Bar != bar {
+				t.Errorf("expected 'foo' = %q, 'bar' = %q, got %+v", foo, bar, conf)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", foo)
+		}
+	}
+	expect("base", "bar!")
+
+	override := filepath.Join(dir, "20-override.yaml")
+	writeConfig(t, override, "foo: \"override\"\n")
+	expect("override", "bar!")
+	if paths, _ := loader.ConfigPaths(); len(paths) != 2 || paths[1] != override {
+		t.Errorf("expected both fragments in merge order, got %v", paths)
+	}
+
+	if err := os.Remove(override); err != nil {
+		t.Fatalf("error removing fragment: %v", err)
+	}
+	expect("base", "bar!")
+
+	if err := loader.SetConfigGlob(filepath.Join(dir, "*.json"), true); err == nil {
+		t.Errorf("expected an error for a required glob with no matches")
+	}
+	if err := loader.SetConfigGlob(filepath.Join(dir, "*.json"), false); err != nil {
+		t.Errorf("error loading an optional glob with no matches: %v", err)
+	}
+	if err := loader.SetConfigGlob(filepath.Join(dir, "[.yaml"), true); err == nil {
+		t.Errorf("expected an error for a malformed glob")
+	}
+}
+
+func TestMinBroadcastInterval(t *testing.T) {
+	const interval = 150 * time.Millisecond
+	loader, err := NewConfigLoader[TestConf]("", WithMinBroadcastInterval(interval))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for conf := range ch {
+			got = append(got, conf.Foo)
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := loader.SetConfigReader(strings.NewReader(fmt.Sprintf("foo: \"%d\"\nbar: \"bar!\"\n", i)), true); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+		if conf := loader.Config(); conf.Foo != fmt.Sprint(i) {
+			t.Errorf("expected Config to return the latest config at once, got %q", conf.Foo)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	time.Sleep(2 * interval)
+	loader.Close()
+	<-done
+
+	if max := int(elapsed/interval) + 2; len(got) > max {
+		t.Errorf("expected at most %d broadcasts, got %d: %v", max, len(got), got)
+	}
+	if len(got) == 0 || got[len(got)-1] != "9" {
+		t.Errorf("expected the last broadcast to be the latest config, got %v", got)
+	}
+}
+
+func TestSetConfigPathAndGet(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	conf, err := loader.SetConfigPathAndGet("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if conf == nil || conf.Foo != "foo!" {
+		t.Errorf("expected the loaded config, got %+v", conf)
+	}
+	if conf, err = loader.SetConfigPathAndGet("testdata/config.yaml"); err != nil || conf == nil || conf.Foo != "foo!" {
+		t.Errorf("expected the current config for the same path, got %+v, %v", conf, err)
+	}
+	if conf, err = loader.SetConfigPathAndGet(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || conf != nil {
+		t.Errorf("expected an error and no config for a missing file, got %+v, %v", conf, err)
+	}
+}
+
+func TestAllowEmpty(t *testing.T) {
+	type emptyConf struct {
+		Foo  string `yaml:"foo"`
+		Port int    `yaml:"port" default:"8080"`
+	}
+	for _, tc := range []struct {
+		name, contents string
+	}{
+		{"ZeroBytes", ""},
+		{"Whitespace", "\n   \n\t\n\n   \n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeConfig(t, path, tc.contents)
+
+			strict, err := NewConfigLoader[emptyConf](path, WithRetry(1, time.Millisecond))
+			if err == nil {
+				t.Errorf("expected an error for an empty required file")
+			}
+			if strict != nil {
+				strict.Close()
+			}
+
+			loader, err := NewConfigLoader[emptyConf](path, WithAllowEmpty(true), WithDecoder(jsonDecoder{}))
+			if err != nil {
+				t.Fatalf("error loading empty config: %v", err)
+			}
+			defer loader.Close()
+			if conf := loader.Config(); conf == nil || conf.Port != 8080 || conf.Foo != "" {
+				t.Errorf("expected the default config, got %+v", conf)
+			}
+			if got := loader.ConfigSource(); got != FromFile {
+				t.Errorf("expected the config to be FromFile, got %v", got)
+			}
+		})
+	}
+
+	loader, err := NewConfigLoader[emptyConf](filepath.Join(t.TempDir(), "missing.yaml"), WithAllowEmpty(true))
+	if err == nil {
+		t.Errorf("expected an error for a missing required file")
+	}
+	if loader != nil {
+		loader.Close()
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithWatchEvents(16), WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if loader.IsPolling() {
+		t.Skip("fsnotify is unavailable")
+	}
+	events := loader.WatchEvents()
+	expect := func(name string, reloaded bool) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Name != name {
+					continue
+				}
+				if ev.Reloaded != reloaded {
+					t.Errorf("expected Reloaded = %v for %s, got %+v", reloaded, name, ev)
+				}
+				return
+			case <-timeout:
+				t.Fatalf("timed out waiting for an event for %s", name)
+			}
+		}
+	}
+
+	writeConfig(t, filepath.Join(dir, "other.yaml"), "unrelated: true\n")
+	expect(filepath.Join(dir, "other.yaml"), false)
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	expect(path, true)
+
+	loader.Close()
+	for range events {
+	}
+
+	plain, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer plain.Close()
+	if plain.WatchEvents() != nil {
+		t.Errorf("expected no watch events without WithWatchEvents")
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+	loader, err := LoadFrom[TestConf]("testdata/config.yaml", true)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if conf := loader.Config(); conf.Foo != "foo!" {
+		t.Errorf("expected the config to be loaded on return, got %+v", conf)
+	}
+	loader.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: [\"not a string\"]\n")
+	if loader, err := LoadFrom[TestConf](path, true); err == nil || loader != nil {
+		t.Errorf("expected a bad required config to fail, got %v, %v", loader, err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if loader, err := LoadFrom[TestConf](missing, true); err == nil || loader != nil {
+		t.Errorf("expected a missing required config to fail, got %v, %v", loader, err)
+	}
+
+	loader, err = LoadFrom[TestConf](missing, false)
+	if err != nil {
+		t.Fatalf("error loading optional config: %v", err)
+	}
+	defer loader.Close()
+	if conf := loader.Config(); conf == nil || conf.Foo != "" {
+		t.Errorf("expected the zero config, got %+v", conf)
+	}
+
+	// An optional config that exists is the first and only load.
+	var buf syncBuffer
+	optional, err := LoadFrom[TestConf]("testdata/config.yaml", false,
+		WithLogger(log.New(&buf, "", 0)),
+		WithRequiredMissingHandler(func() (TestConf, error) { return TestConf{Foo: "fallback"}, nil }))
+	if err != nil {
+		t.Fatalf("error loading optional config: %v", err)
+	}
+	defer optional.Close()
+	if conf := optional.Config(); conf.Foo != "foo!" {
+		t.Errorf("expected the file's config, got %+v", conf)
+	}
+	if stats := optional.Stats(); stats.ReloadCount != 1 || stats.ErrorCount != 0 || stats.LastErrorMessage != "" {
+		t.Errorf("expected one clean load, got %+v", stats)
+	}
+	if logged := buf.String(); strings.Contains(logged, "config error") {
+		t.Errorf("expected no error to be logged, got:\n%s", logged)
+	}
+}
+
+func TestPollJitter(t *testing.T) {
+	const d = time.Second
+	if got := jitter(d, 0); got != d {
+		t.Errorf("expected no jitter by default, got %v", got)
+	}
+	spread := false
+	for i := 0; i < 100; i++ {
+		got := jitter(d, 0.2)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("expected %v ±20%%, got %v", d, got)
+		}
+		if got != d {
+			spread = true
+		}
+	}
+	if !spread {
+		t.Errorf("expected the interval to be randomized")
+	}
+
+	for _, fraction := range []float64{-0.1, 1} {
+		if _, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithPollJitter(fraction)); err == nil {
+			t.Errorf("expected an error for a jitter of %v", fraction)
+		}
+	}
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithPollJitter(0.1))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	loader.Close()
+}
+
+func TestShadowDecode(t *testing.T) {
+	// The next version of TestConf requires a port.
+	type shadowConf struct {
+		Foo  string `yaml:"foo"`
+		Port int    `yaml:"port" configloader:"required"`
+	}
+	type result struct {
+		conf shadowConf
+		err  error
+	}
+	var results []result
+	loader, err := NewConfigLoader[TestConf]("", WithShadowDecode(func(conf shadowConf, err error) {
+		results = append(results, result{conf, err})
+	}))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"one\"\nbar: \"bar!\"\n"), true); err != nil {
+		t.Fatalf("expected the shadow failure not to affect the load, got %v", err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"two\"\nbar: \"bar!\"\nport: 8080\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 shadow results, got %d", len(results))
+	}
+	if err := results[0].err; err == nil || !strings.Contains(err.Error(), "port") {
+		t.Errorf("expected the shadow decode to fail on the missing port, got %v", err)
+	}
+	if r := results[1]; r.err != nil || r.conf != (shadowConf{Foo: "two", Port: 8080}) {
+		t.Errorf("expected the shadow decode to succeed, got %+v, %v", r.conf, r.err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithMinBroadcastInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	// No sleeping until the watcher catches up: Sync is a barrier.
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	if err := loader.Sync(); err != nil {
+		t.Fatalf("error syncing config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two' after Sync, got %q", got)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	default:
+		t.Errorf("expected Sync to deliver the broadcast held back by the rate limit")
+	}
+
+	loader.Freeze()
+	if err := loader.Sync(); err == nil {
+		t.Errorf("expected an error syncing a frozen loader")
+	}
+}
@@ -0,0 +1,86 @@
+package configloader
+
+import (
+	"reflect"
+)
+
+// deepCopy returns a copy of v that shares no pointers, slices or maps
+// with it. Unexported struct fields are copied shallowly, and cyclic
+// data is not supported.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(deepCopy(v.Elem()))
+		return n
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if n.Field(i).CanSet() {
+				n.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return n
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			n.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(deepCopy(v.Elem()))
+		return n
+	default:
+		return v
+	}
+}
+
+// copyConf returns the copy of conf handed out to a caller: the result of
+// the clone function if one was set with WithCloneFunc, a deep copy if
+// WithDeepCopy is on, or conf itself otherwise.
+func (b *ConfigLoader[Config]) copyConf(conf Config) Config {
+	if b.clone != nil {
+		return b.clone(conf)
+	}
+	if b.opts.deepCopy {
+		return deepCopy(reflect.ValueOf(&conf)).Elem().Interface().(Config)
+	}
+	return conf
+}
+
+// copyConfPtr is like copyConf for the current config pointer, which may
+// be nil.
+func (b *ConfigLoader[Config]) copyConfPtr(conf *Config) *Config {
+	if conf == nil || (b.clone == nil && !b.opts.deepCopy) {
+		return conf
+	}
+	c := b.copyConf(*conf)
+	return &c
+}
@@ -0,0 +1,81 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+)
+
+type MapConf struct {
+	Labels map[string]string
+	Hosts  []string
+	Nested *struct {
+		Name string
+	}
+}
+
+const mapConfYAML = "labels:\n  env: prod\nhosts: [a, b]\nnested:\n  name: n\n"
+
+func TestDeepCopy(t *testing.T) {
+	loader, err := NewConfigLoader[MapConf]("", WithDeepCopy(true), WithHistorySize(2))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader(mapConfYAML), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	a := <-loader.Subscribe()
+	b := <-loader.Subscribe()
+	a.Labels["env"] = "mutated"
+	a.Hosts[0] = "mutated"
+	a.Nested.Name = "mutated"
+
+	if b.Labels["env"] != "prod" || b.Hosts[0] != "a" || b.Nested.Name != "n" {
+		t.Errorf("expected subscribers to get independent copies, got %+v", b)
+	}
+
+	c := loader.Config()
+	c.Labels["env"] = "mutated"
+	if got := loader.Config().Labels["env"]; got != "prod" {
+		t.Errorf("expected Config to return independent copies, got %q", got)
+	}
+
+	history := loader.History()
+	history[len(history)-1].Config.Labels["env"] = "mutated"
+	if got := loader.Config().Labels["env"]; got != "prod" {
+		t.Errorf("expected History to return independent copies, got %q", got)
+	}
+}
+
+func TestCloneFunc(t *testing.T) {
+	clones := 0
+	clone := func(c MapConf) MapConf {
+		clones++
+		labels := make(map[string]string, len(c.Labels))
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		c.Labels = labels
+		return c
+	}
+	loader, err := NewConfigLoader[MapConf]("", WithCloneFunc(clone))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	loader.SetConfigReader(strings.NewReader(mapConfYAML), true)
+	loader.Config().Labels["env"] = "mutated"
+	if got := loader.Config().Labels["env"]; got != "prod" {
+		t.Errorf("expected the clone function to copy the map, got %q", got)
+	}
+	if clones == 0 {
+		t.Errorf("expected the clone function to be used")
+	}
+
+	if _, err := NewConfigLoader[TestConf]("", WithCloneFunc(clone)); err == nil {
+		t.Errorf("expected an error for a clone function of the wrong type")
+	}
+}
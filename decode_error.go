@@ -0,0 +1,95 @@
+package configloader
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlErrorLine matches a line number at the start of one of the messages
+// in a yaml.TypeError.
+var yamlErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// yamlKey matches a line holding a YAML mapping key, possibly as the
+// first key of a sequence item.
+var yamlKey = regexp.MustCompile(`^(\s*)(?:-\s+)?([^\s#:][^:#]*?)\s*:(?:\s|$)`)
+
+// decodeError is a decoding error annotated with the key paths of the
+// fields it concerns.
+type decodeError struct {
+	msgs []string
+	err  error
+}
+
+func (e decodeError) Error() string {
+	return strings.Join(e.msgs, "; ")
+}
+
+func (e decodeError) Unwrap() error {
+	return e.err
+}
+
+// describeDecodeError annotates a YAML type error from decoding data
+// with the dotted key path of each offending field, found from the line
+// numbers it reports. If what was decoded is data retagged by retagDoc,
+// srcLine maps its line numbers back to data, so that both the lines and
+// the keys are as the user wrote them. Other errors are returned
+// unchanged.
+func describeDecodeError(err error, data []byte, srcLine func(int) int) error {
+	var te *yaml.TypeError
+	if !errors.As(err, &te) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	msgs := make([]string, len(te.Errors))
+	for i, msg := range te.Errors {
+		msgs[i] = msg
+		m := yamlErrorLine.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if srcLine != nil {
+			if n = srcLine(n); n == 0 {
+				// The line can't be placed in data, so leave it out.
+				msgs[i] = m[2]
+				continue
+			}
+		}
+		if key := yamlKeyPath(lines, n); key != "" {
+			msgs[i] = fmt.Sprintf("line %d: field %q: %s", n, key, m[2])
+		} else {
+			msgs[i] = fmt.Sprintf("line %d: %s", n, m[2])
+		}
+	}
+	return decodeError{msgs: msgs, err: err}
+}
+
+// yamlKeyPath returns the dotted path of the key whose value is at the
+// 1-based line n, by walking up to the keys of less indented lines.
+func yamlKeyPath(lines []string, n int) string {
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	var path []string
+	indent := -1
+	for i := n - 1; i >= 0; i-- {
+		m := yamlKey.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		if indent >= 0 && len(m[1]) >= indent {
+			continue
+		}
+		path = append([]string{m[2]}, path...)
+		indent = len(m[1])
+		if indent == 0 {
+			break
+		}
+	}
+	return strings.Join(path, ".")
+}
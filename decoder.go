@@ -0,0 +1,112 @@
+package configloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Decoder converts between the on-disk representation of a config and
+// the Go value it is loaded into.
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+	Marshal(v any) ([]byte, error)
+}
+
+// StrictDecoder is implemented by Decoders that can reject input
+// containing keys that don't map to a field. See WithStrict.
+type StrictDecoder interface {
+	Decoder
+	UnmarshalStrict(data []byte, v any) error
+}
+
+// decoderForPath picks the decoder to use for path. An explicitly set
+// decoder always wins; otherwise the file extension decides, looking
+// past a ".gz" extension.
+func decoderForPath(o *options, path string) Decoder {
+	if !o.decoderSet && strings.EqualFold(filepath.Ext(trimGzipExt(path)), ".toml") {
+		return TOMLDecoder{}
+	}
+	return o.decoder
+}
+
+// YAMLDecoder is the default Decoder, backed by gopkg.in/yaml.v3, which
+// handles anchors, aliases and merge keys more reliably than yaml.v2 did.
+// Configs that relied on yaml.v2 may notice that:
+//
+//   - a key that appears twice in the same mapping is always an error,
+//     not only with WithStrict;
+//   - yes, no, on and off are booleans only when decoded into a bool
+//     field, and strings otherwise;
+//   - mappings decoded into an interface are map[string]any rather than
+//     map[any]any;
+//   - a time.Duration must be written as a duration string, such as "5s",
+//     rather than an integer number of nanoseconds.
+//
+// Marshaled configs are still indented by two spaces, as before.
+type YAMLDecoder struct{}
+
+func (YAMLDecoder) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+func (YAMLDecoder) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (YAMLDecoder) UnmarshalStrict(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// Like Unmarshal, an empty document leaves v alone.
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// TOMLDecoder is a Decoder backed by github.com/BurntSushi/toml. It is
+// used automatically for paths ending in ".toml" unless a decoder was set
+// with WithDecoder.
+type TOMLDecoder struct{}
+
+func (TOMLDecoder) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
+
+func (TOMLDecoder) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (TOMLDecoder) UnmarshalStrict(data []byte, v any) error {
+	md, err := toml.Decode(string(data), v)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package configloader
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonDecoder struct{}
+
+func (jsonDecoder) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonDecoder) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+
+func TestCustomDecoder(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.json", WithDecoder(jsonDecoder{}))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Foo != "foo!" {
+		t.Errorf("expected 'foo' = 'foo!', got %q", conf.Foo)
+	}
+	if conf.Bar != "bar!" {
+		t.Errorf("expected 'bar' = 'bar!', got %q", conf.Bar)
+	}
+}
+
+func TestNilDecoder(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml", WithDecoder(nil))
+	if err == nil {
+		t.Fatalf("expected an error for a nil decoder")
+	}
+	if loader != nil {
+		t.Errorf("expected a nil loader on option error")
+	}
+}
+
+func TestStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithStrict(true))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\nbaz: \"typo\"\n")
+	if err := loader.Reload(); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+	if err := loader.LastError(); err == nil {
+		t.Errorf("expected LastError to be set")
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
+
+func TestStrictRequiresStrictDecoder(t *testing.T) {
+	_, err := NewConfigLoader[TestConf]("testdata/config.json", WithDecoder(jsonDecoder{}), WithStrict(true))
+	if err == nil {
+		t.Errorf("expected an error for strict mode with a non-strict decoder")
+	}
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.toml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Foo != "foo!" {
+		t.Errorf("expected 'foo' = 'foo!', got %q", conf.Foo)
+	}
+	if conf.Bar != "bar!" {
+		t.Errorf("expected 'bar' = 'bar!', got %q", conf.Bar)
+	}
+}
+
+func TestWriteTOMLConfig(t *testing.T) {
+	for _, name := range []string{"config.toml", "config.toml.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		loader, err := NewConfigLoader[TestConf](path, WithStrict(true))
+		if loader == nil {
+			t.Fatalf("error creating config loader: %v", err)
+		}
+		defer loader.Close()
+
+		if err := loader.WriteConfig(TestConf{Foo: "written", Bar: "bar!"}); err != nil {
+			t.Fatalf("error writing %s: %v", name, err)
+		}
+		if got := loader.Config().Foo; got != "written" {
+			t.Errorf("expected the written %s to load, got %q", name, got)
+		}
+		if err := loader.Reload(); err != nil {
+			t.Errorf("expected %s to reload as TOML, got %v", name, err)
+		}
+	}
+}
+
+func TestTOMLDecoderOption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.conf")
+	writeConfig(t, path, "foo = \"foo!\"\nbar = \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithDecoder(TOMLDecoder{}), WithStrict(true))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if got := loader.Config().Foo; got != "foo!" {
+		t.Errorf("expected 'foo' = 'foo!', got %q", got)
+	}
+
+	writeConfig(t, path, "foo = \"foo!\"\nbaz = \"typo\"\n")
+	if err := loader.Reload(); err == nil {
+		t.Errorf("expected an error for an unknown key in strict mode")
+	}
+}
+
+func TestDecodeErrorNamesField(t *testing.T) {
+	type server struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	type conf struct {
+		Name   string `yaml:"name"`
+		Server server `yaml:"server"`
+	}
+	loader, err := NewConfigLoader[conf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	errs := loader.SubscribeErrors()
+
+	yaml := "name: [1, 2, 3]\nserver:\n  host: localhost\n  port: eighty\n"
+	err = loader.SetConfigReader(strings.NewReader(yaml), true)
+	if err == nil {
+		t.Fatalf("expected a decoding error")
+	}
+	for _, want := range []string{`line 1: field "name"`, `line 4: field "server.port"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+	if last := loader.LastError(); last == nil || last.Error() != err.Error() {
+		t.Errorf("expected LastError to be the annotated error, got %v", last)
+	}
+	select {
+	case e := <-errs:
+		if !strings.Contains(e.Error(), "server.port") {
+			t.Errorf("expected the error channel to get the annotated error, got %v", e)
+		}
+	default:
+		t.Errorf("expected an error to be broadcast")
+	}
+}
+
+func TestYAMLMergeKeys(t *testing.T) {
+	type server struct {
+		Host    string
+		Port    int
+		Verbose bool
+	}
+	type servers struct {
+		Primary   server
+		Secondary server
+	}
+	loader, err := NewConfigLoader[servers]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	yaml := `defaults: &defaults
+  port: 8080
+  verbose: yes
+primary:
+  <<: *defaults
+  host: a.example.com
+secondary:
+  <<: *defaults
+  host: b.example.com
+  port: 9090
+`
+	if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	conf := loader.Config()
+	if want := (server{Host: "a.example.com", Port: 8080, Verbose: true}); conf.Primary != want {
+		t.Errorf("expected primary = %+v, got %+v", want, conf.Primary)
+	}
+	if want := (server{Host: "b.example.com", Port: 9090, Verbose: true}); conf.Secondary != want {
+		t.Errorf("expected secondary = %+v, got %+v", want, conf.Secondary)
+	}
+}
+
+// panicDecoder is a YAMLDecoder that panics on configs containing
+// "boom", like a decoder with a bug.
+type panicDecoder struct{ YAMLDecoder }
+
+func (d panicDecoder) Unmarshal(data []byte, v any) error {
+	if strings.Contains(string(data), "boom") {
+		panic("decoder bug")
+	}
+	return d.YAMLDecoder.Unmarshal(data, v)
+}
+
+func TestDecoderPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithDecoder(panicDecoder{}), WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+	errs := loader.SubscribeErrors()
+
+	writeConfig(t, path, "foo: \"boom\"\nbar: \"bar!\"\n")
+	if err := loader.Reload(); err == nil || !strings.Contains(err.Error(), "decoder bug") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+	if err := loader.LastError(); err == nil || !strings.Contains(err.Error(), "decoder bug") {
+		t.Errorf("expected LastError to report the panic, got %v", err)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "decoder bug") {
+			t.Errorf("expected the panic to be broadcast, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for the panic to be broadcast")
+	}
+
+	// The watcher survives and picks up the next good config.
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the watcher to reload")
+	}
+
+	// Decoding outside of a load is protected too, and leaves the loader
+	// usable.
+	if err := loader.ApplyBytes([]byte("foo: \"boom\"\n")); err == nil || !strings.Contains(err.Error(), "decoder bug") {
+		t.Errorf("expected ApplyBytes to return the panic as an error, got %v", err)
+	}
+	if err := loader.ValidateBytes([]byte("foo: \"boom\"\n")); err == nil || !strings.Contains(err.Error(), "decoder bug") {
+		t.Errorf("expected ValidateBytes to return the panic as an error, got %v", err)
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package configloader
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// applyDefaults sets every zero-valued field of v tagged `default:"..."`
+// by parsing the tag value. Nested structs are walked recursively.
+func applyDefaults(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fv.IsZero() {
+			continue
+		}
+		if err := setFromString(fv, def); err != nil {
+			return fmt.Errorf("default for %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// defaultConfig returns a config with nothing set but the defaults from
+// `default` tags.
+func (b *ConfigLoader[Config]) defaultConfig() (*Config, error) {
+	conf := new(Config)
+	if err := applyDefaults(reflect.ValueOf(conf)); err != nil {
+		return nil, fmt.Errorf("could not apply defaults: %w", err)
+	}
+	return conf, nil
+}
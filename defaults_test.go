@@ -0,0 +1,112 @@
+package configloader
+
+import (
+	"testing"
+	"time"
+)
+
+type DefaultsConf struct {
+	Foo     string        `default:"default foo"`
+	Bar     string        `default:"default bar"`
+	Port    int           `default:"8080"`
+	Big     int64         `default:"1099511627776"`
+	Debug   bool          `default:"true"`
+	Ratio   float64       `default:"0.5"`
+	Timeout time.Duration `default:"30s"`
+	Nested  struct {
+		Name string `default:"nested"`
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	loader, err := NewConfigLoader[DefaultsConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Foo != "foo!" {
+		t.Errorf("expected file value 'foo!' to win over default, got %q", conf.Foo)
+	}
+	if conf.Port != 8080 {
+		t.Errorf("expected 'port' = 8080, got %d", conf.Port)
+	}
+	if conf.Big != 1<<40 {
+		t.Errorf("expected 'big' = %d, got %d", int64(1<<40), conf.Big)
+	}
+	if !conf.Debug {
+		t.Errorf("expected 'debug' = true")
+	}
+	if conf.Ratio != 0.5 {
+		t.Errorf("expected 'ratio' = 0.5, got %v", conf.Ratio)
+	}
+	if conf.Timeout != 30*time.Second {
+		t.Errorf("expected 'timeout' = 30s, got %v", conf.Timeout)
+	}
+	if conf.Nested.Name != "nested" {
+		t.Errorf("expected 'nested.name' = 'nested', got %q", conf.Nested.Name)
+	}
+}
+
+func TestDefaultsBeforeCallback(t *testing.T) {
+	loader, err := NewConfigLoader[DefaultsConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	var seen int
+	loader.RegisterCallback(func(c DefaultsConf) (DefaultsConf, error) {
+		seen = c.Port
+		c.Port = 9090
+		return c, nil
+	})
+	if err := loader.SetConfigPath("testdata/config.yaml"); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if seen != 8080 {
+		t.Errorf("expected callback to see the default 8080, got %d", seen)
+	}
+	if got := loader.Config().Port; got != 9090 {
+		t.Errorf("expected callback to override default, got %d", got)
+	}
+}
+
+func TestEagerDefault(t *testing.T) {
+	loader, err := NewConfigLoader[DefaultsConf]("", WithEagerDefault(true))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf == nil {
+		t.Fatalf("expected a default config before any load")
+	}
+	if conf.Foo != "default foo" || conf.Port != 8080 {
+		t.Errorf("expected defaults, got %+v", conf)
+	}
+
+	ch := loader.Subscribe()
+	if got := (<-ch).Foo; got != "default foo" {
+		t.Errorf("expected the default to be replayed, got %q", got)
+	}
+
+	if err := loader.SetConfigPath("testdata/config.yaml"); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Foo != "foo!" {
+			t.Errorf("expected 'foo' = 'foo!', got %q", conf.Foo)
+		}
+	default:
+		t.Fatalf("expected the real config to be broadcast")
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected a single broadcast, got %+v", conf)
+	default:
+	}
+}
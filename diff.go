@@ -0,0 +1,167 @@
+package configloader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind says how a field differs between two configs.
+type ChangeKind int
+
+const (
+	// FieldChanged is a field with different values in both configs.
+	FieldChanged ChangeKind = iota
+	// FieldAdded is a field only in the new config.
+	FieldAdded
+	// FieldRemoved is a field only in the old config.
+	FieldRemoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case FieldAdded:
+		return "added"
+	case FieldRemoved:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// FieldChange describes a leaf field that differs between two configs.
+// Old is nil for an added field and New for a removed one. The values of
+// fields tagged `secret:"true"` are replaced by "***".
+type FieldChange struct {
+	Path string
+	Kind ChangeKind
+	Old  any
+	New  any
+}
+
+func (c FieldChange) String() string {
+	switch c.Kind {
+	case FieldAdded:
+		return fmt.Sprintf("%s (%v)", c.Path, c.New)
+	case FieldRemoved:
+		return fmt.Sprintf("%s (%v)", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("%s (%v→%v)", c.Path, c.Old, c.New)
+	}
+}
+
+// Diff returns the leaf fields that differ between old and new, named by
+// their dotted key paths, such as "server.timeout", in field order. Maps
+// with string keys are compared key by key, in sorted order, and a nil
+// pointer counts as absent; anything else, including slices, is compared
+// as a whole with reflect.DeepEqual.
+func (b *ConfigLoader[Config]) Diff(old, new Config) []FieldChange {
+	return diffValues(reflect.ValueOf(&old).Elem(), reflect.ValueOf(&new).Elem(), "", b.opts.tagName, false)
+}
+
+// diffValues returns the differences between old and new, found at
+// path. Invalid values are absent. secret is set for the values of
+// secret fields.
+func diffValues(old, new reflect.Value, path, tagName string, secret bool) []FieldChange {
+	old, new = derefValue(old), derefValue(new)
+	switch {
+	case !old.IsValid() && !new.IsValid():
+		return nil
+	case !old.IsValid():
+		return []FieldChange{{Path: path, Kind: FieldAdded, New: diffValue(new, secret)}}
+	case !new.IsValid():
+		return []FieldChange{{Path: path, Kind: FieldRemoved, Old: diffValue(old, secret)}}
+	}
+
+	if old.Type() == new.Type() {
+		switch old.Kind() {
+		case reflect.Struct:
+			var changes []FieldChange
+			t := old.Type()
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if !field.IsExported() {
+					continue
+				}
+				changes = append(changes, diffValues(old.Field(i), new.Field(i), joinPath(path, fieldKey(field, tagName)), tagName, secret || isSecret(field))...)
+			}
+			return changes
+		case reflect.Map:
+			if old.Type().Key().Kind() != reflect.String {
+				break
+			}
+			keys := map[string]reflect.Value{}
+			for _, m := range []reflect.Value{old, new} {
+				iter := m.MapRange()
+				for iter.Next() {
+					keys[iter.Key().String()] = iter.Key()
+				}
+			}
+			names := make([]string, 0, len(keys))
+			for name := range keys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var changes []FieldChange
+			for _, name := range names {
+				changes = append(changes, diffValues(old.MapIndex(keys[name]), new.MapIndex(keys[name]), joinPath(path, name), tagName, secret)...)
+			}
+			return changes
+		}
+	}
+
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return nil
+	}
+	return []FieldChange{{Path: path, Kind: FieldChanged, Old: diffValue(old, secret), New: diffValue(new, secret)}}
+}
+
+// derefValue follows pointers and interfaces, returning an invalid value
+// for nil.
+func derefValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// diffValue returns v as reported in a FieldChange, with any secrets
+// redacted.
+func diffValue(v reflect.Value, secret bool) any {
+	if secret {
+		return redacted
+	}
+	if r, changed := redactValue(v); changed {
+		return r
+	}
+	return v.Interface()
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// formatChanges summarizes changes for a log line, e.g. "changed:
+// server.timeout (30s→1m0s); added: features.beta (true)".
+func formatChanges(changes []FieldChange) string {
+	var parts []string
+	for _, kind := range []ChangeKind{FieldChanged, FieldAdded, FieldRemoved} {
+		var fields []string
+		for _, c := range changes {
+			if c.Kind == kind {
+				fields = append(fields, c.String())
+			}
+		}
+		if len(fields) > 0 {
+			parts = append(parts, kind.String()+": "+strings.Join(fields, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,98 @@
+package configloader
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type diffConf struct {
+	Server struct {
+		Host    string        `yaml:"host"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"server"`
+	Features map[string]bool `yaml:"features"`
+	Password string          `yaml:"password" secret:"true"`
+}
+
+func TestDiff(t *testing.T) {
+	loader, err := NewConfigLoader[diffConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	var old, new diffConf
+	old.Server.Host = "example.com"
+	old.Server.Timeout = 30 * time.Second
+	old.Features = map[string]bool{"alpha": true, "legacy": true}
+	old.Password = "hunter2"
+	new = old
+	new.Server.Timeout = time.Minute
+	new.Features = map[string]bool{"alpha": true, "beta": true}
+	new.Password = "correct horse"
+
+	want := []FieldChange{
+		{Path: "server.timeout", Kind: FieldChanged, Old: 30 * time.Second, New: time.Minute},
+		{Path: "features.beta", Kind: FieldAdded, New: true},
+		{Path: "features.legacy", Kind: FieldRemoved, Old: true},
+		{Path: "password", Kind: FieldChanged, Old: "***", New: "***"},
+	}
+	if got := loader.Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := loader.Diff(old, old); len(got) != 0 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+	if got, want := formatChanges(loader.Diff(old, new)), "changed: server.timeout (30s→1m0s), password (***→***); added: features.beta (true); removed: features.legacy (true)"; got != want {
+		t.Errorf("expected summary %q, got %q", want, got)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that the watcher can log to while the test
+// reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestChangeLogging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "server:\n  host: example.com\n  timeout: 30s\npassword: hunter2\n")
+
+	var buf syncBuffer
+	loader, err := NewConfigLoader[diffConf](path, WithChangeLogging(true), WithLogger(log.New(&buf, "", 0)))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	writeConfig(t, path, "server:\n  host: example.com\n  timeout: 1m\npassword: swordfish\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, "changed: server.timeout (30s→1m0s), password (***→***)") {
+		t.Errorf("expected the change to be logged, got:\n%s", logged)
+	}
+	if strings.Contains(logged, "swordfish") || strings.Contains(logged, "hunter2") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", logged)
+	}
+}
@@ -0,0 +1,136 @@
+package configloader
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envSeparators are the separators used to split env values into slices
+// and maps. See WithEnvSeparators.
+type envSeparators struct {
+	list     string
+	keyValue string
+}
+
+// applyEnvOverrides sets every field of v tagged `env:"NAME"` from the
+// environment variable prefix+NAME, if it is set. Nested structs are
+// walked recursively. Unexported fields are skipped.
+func applyEnvOverrides(v reflect.Value, prefix string, seps envSeparators) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if err := applyEnvOverrides(fv, prefix, seps); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := os.LookupEnv(prefix + name)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, val, seps); err != nil {
+			return fmt.Errorf("env %s: %w", prefix+name, err)
+		}
+	}
+	return nil
+}
+
+// setFromEnv is like setFromString, but also splits s into slices, on
+// seps.list, and into maps with string keys, on seps.list and then
+// seps.keyValue. An empty s gives an empty slice or map.
+func setFromEnv(v reflect.Value, s string, seps envSeparators) error {
+	switch {
+	case v.Kind() == reflect.Slice:
+		var items []string
+		if s != "" {
+			items = strings.Split(s, seps.list)
+		}
+		n := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromString(n.Index(i), strings.TrimSpace(item)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(n)
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		n := reflect.MakeMap(v.Type())
+		if s != "" {
+			for _, item := range strings.Split(s, seps.list) {
+				key, val, ok := strings.Cut(item, seps.keyValue)
+				key = strings.TrimSpace(key)
+				if !ok || key == "" {
+					return fmt.Errorf("malformed map entry %q, want key%svalue", item, seps.keyValue)
+				}
+				kv := reflect.New(v.Type().Key()).Elem()
+				kv.SetString(key)
+				vv := reflect.New(v.Type().Elem()).Elem()
+				if err := setFromString(vv, strings.TrimSpace(val)); err != nil {
+					return fmt.Errorf("key %q: %w", key, err)
+				}
+				n.SetMapIndex(kv, vv)
+			}
+		}
+		v.Set(n)
+	default:
+		return setFromString(v, s)
+	}
+	return nil
+}
+
+// setFromString parses s into v according to v's type.
+func setFromString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		bv, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(bv)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package configloader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type EnvConf struct {
+	Foo     string        `env:"FOO"`
+	Port    int           `env:"PORT"`
+	Debug   bool          `env:"DEBUG"`
+	Timeout time.Duration `env:"TIMEOUT"`
+	Nested  struct {
+		Name string `env:"NAME"`
+	}
+	hidden string `env:"HIDDEN"`
+}
+
+func TestEnvOverride(t *testing.T) {
+	t.Setenv("TEST_FOO", "from env")
+	t.Setenv("TEST_PORT", "8080")
+	t.Setenv("TEST_DEBUG", "true")
+	t.Setenv("TEST_TIMEOUT", "3s")
+	t.Setenv("TEST_NAME", "nested")
+	t.Setenv("TEST_HIDDEN", "nope")
+
+	loader, err := NewConfigLoader[EnvConf]("testdata/config.yaml", WithEnvOverride("TEST_"))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Foo != "from env" {
+		t.Errorf("expected 'foo' = 'from env', got %q", conf.Foo)
+	}
+	if conf.Port != 8080 {
+		t.Errorf("expected 'port' = 8080, got %d", conf.Port)
+	}
+	if !conf.Debug {
+		t.Errorf("expected 'debug' = true")
+	}
+	if conf.Timeout != 3*time.Second {
+		t.Errorf("expected 'timeout' = 3s, got %v", conf.Timeout)
+	}
+	if conf.Nested.Name != "nested" {
+		t.Errorf("expected 'nested.name' = 'nested', got %q", conf.Nested.Name)
+	}
+	if conf.hidden != "" {
+		t.Errorf("expected unexported field to be skipped, got %q", conf.hidden)
+	}
+}
+
+func TestEnvOverrideInvalid(t *testing.T) {
+	t.Setenv("TEST_PORT", "not a number")
+
+	loader, err := NewConfigLoader[EnvConf]("testdata/config.yaml", WithEnvOverride("TEST_"))
+	if err == nil {
+		t.Errorf("expected an error for an unparseable env value")
+	}
+	if loader != nil {
+		loader.Close()
+	}
+}
+
+type EnvListConf struct {
+	Hosts  []string          `env:"HOSTS"`
+	Ports  []int             `env:"PORTS"`
+	Labels map[string]string `env:"LABELS"`
+}
+
+func TestEnvOverrideListAndMap(t *testing.T) {
+	t.Setenv("TEST_HOSTS", "a.com, b.com")
+	t.Setenv("TEST_PORTS", "80,443")
+	t.Setenv("TEST_LABELS", "env=prod,team=infra")
+
+	loader, err := NewConfigLoader[EnvListConf]("testdata/config.yaml", WithEnvOverride("TEST_"))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if !reflect.DeepEqual(conf.Hosts, []string{"a.com", "b.com"}) {
+		t.Errorf("expected hosts [a.com b.com], got %q", conf.Hosts)
+	}
+	if !reflect.DeepEqual(conf.Ports, []int{80, 443}) {
+		t.Errorf("expected ports [80 443], got %v", conf.Ports)
+	}
+	if want := map[string]string{"env": "prod", "team": "infra"}; !reflect.DeepEqual(conf.Labels, want) {
+		t.Errorf("expected labels %v, got %v", want, conf.Labels)
+	}
+}
+
+func TestEnvSeparators(t *testing.T) {
+	t.Setenv("TEST_HOSTS", "a.com;b.com")
+	t.Setenv("TEST_LABELS", "env:prod;team:infra")
+
+	loader, err := NewConfigLoader[EnvListConf]("testdata/config.yaml",
+		WithEnvOverride("TEST_"), WithEnvSeparators(";", ":"))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if !reflect.DeepEqual(conf.Hosts, []string{"a.com", "b.com"}) {
+		t.Errorf("expected hosts [a.com b.com], got %q", conf.Hosts)
+	}
+	if want := map[string]string{"env": "prod", "team": "infra"}; !reflect.DeepEqual(conf.Labels, want) {
+		t.Errorf("expected labels %v, got %v", want, conf.Labels)
+	}
+}
+
+func TestEnvOverrideMalformedMap(t *testing.T) {
+	t.Setenv("TEST_LABELS", "env=prod,oops")
+
+	loader, err := NewConfigLoader[EnvListConf]("testdata/config.yaml", WithEnvOverride("TEST_"))
+	if err == nil || !strings.Contains(err.Error(), "TEST_LABELS") {
+		t.Errorf("expected an error naming TEST_LABELS, got %v", err)
+	}
+	if loader != nil {
+		loader.Close()
+	}
+}
@@ -0,0 +1,49 @@
+package configloader
+
+import (
+	"reflect"
+)
+
+type fieldSub struct {
+	path string
+	ch   chan any
+}
+
+// SubscribeField returns a channel that receives the value at a dotted
+// key path, such as "logging.level", whenever a newly loaded config
+// changes it, as compared by reflect.DeepEqual. The value is nil if the
+// path no longer exists. The current value is not sent on subscribing;
+// use the Get methods for that. Like Subscribe, a slow reader gets the
+// latest value rather than a backlog. The channel is closed when the
+// loader is closed.
+func (b *ConfigLoader[Config]) SubscribeField(path string) chan any {
+	ch := make(chan any, b.opts.subscribeBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.fieldSubs = append(b.fieldSubs, fieldSub{path: path, ch: ch})
+	return ch
+}
+
+// broadcastFields must be called with b.mu held. It sends the fields that
+// differ between old and conf to their subscribers.
+func (b *ConfigLoader[Config]) broadcastFields(old, conf *Config) {
+	for _, sub := range b.fieldSubs {
+		oldV, oldOK := lookupPath(reflect.ValueOf(old), sub.path, b.opts.tagName)
+		newV, newOK := lookupPath(reflect.ValueOf(conf), sub.path, b.opts.tagName)
+		if oldOK == newOK && (!newOK || reflect.DeepEqual(oldV.Interface(), newV.Interface())) {
+			continue
+		}
+		var v any
+		if newOK {
+			v = deepCopy(newV).Interface()
+		}
+		if sendLatest(sub.ch, v) {
+			b.stats.DroppedUpdates++
+			b.logf("field subscriber channel for %q is full, replaced stale value", sub.path)
+		}
+	}
+}
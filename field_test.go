@@ -0,0 +1,68 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeField(t *testing.T) {
+	type section struct {
+		Level string `yaml:"level"`
+	}
+	type sectioned struct {
+		Logging section `yaml:"logging"`
+		Server  section `yaml:"server"`
+	}
+	loader, err := NewConfigLoader[sectioned]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+
+	ch := loader.SubscribeField("logging.level")
+	load := func(yaml string) {
+		t.Helper()
+		if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+	}
+	expect := func(want any) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		default:
+			t.Errorf("expected a change to %v", want)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case got := <-ch:
+			t.Errorf("expected no change, got %v", got)
+		default:
+		}
+	}
+
+	load("logging:\n  level: info\nserver:\n  level: a\n")
+	expect("info")
+	load("logging:\n  level: info\nserver:\n  level: b\n")
+	expectNone()
+	load("logging:\n  level: debug\nserver:\n  level: b\n")
+	expect("debug")
+
+	loader.Close()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for channel to be closed")
+	}
+	if _, ok := <-loader.SubscribeField("server.level"); ok {
+		t.Errorf("expected subscribing after close to return a closed channel")
+	}
+}
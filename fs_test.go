@@ -0,0 +1,37 @@
+package configloader
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/defaults.yaml": {Data: []byte("foo: \"embedded\"\nbar: \"bar!\"\n")},
+	}
+	loader, err := NewConfigLoader[TestConf]("config/defaults.yaml", WithFS(fsys))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if got := loader.Config().Foo; got != "embedded" {
+		t.Errorf("expected 'foo' = 'embedded', got %q", got)
+	}
+
+	if mode := loader.Stats().WatchMode; mode != WatchModeNone {
+		t.Errorf("expected watch mode %q, got %q", WatchModeNone, mode)
+	}
+
+	fsys["config/defaults.yaml"] = &fstest.MapFile{Data: []byte("foo: \"changed\"\nbar: \"bar!\"\n")}
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "changed" {
+		t.Errorf("expected 'foo' = 'changed', got %q", got)
+	}
+
+	if err := loader.WriteConfig(TestConf{Foo: "nope"}); err == nil {
+		t.Errorf("expected WriteConfig to fail for an fs.FS")
+	}
+}
@@ -0,0 +1,106 @@
+package configloader
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// lookupPath follows a dotted path of config keys from v. Struct fields
+// are matched by their config key (see fieldKey), and maps with string
+// keys by key.
+func lookupPath(v reflect.Value, path, tagName string) (reflect.Value, bool) {
+	for _, seg := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			found := false
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if field.IsExported() && fieldKey(field, tagName) == seg {
+					v = v.Field(i)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, false
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			v = v.MapIndex(reflect.ValueOf(seg).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return reflect.Value{}, false
+			}
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// lookup finds path in the current config.
+func (b *ConfigLoader[Config]) lookup(path string) (reflect.Value, bool) {
+	b.mu.Lock()
+	conf := b.conf
+	b.mu.Unlock()
+	if conf == nil {
+		return reflect.Value{}, false
+	}
+	// Loaded configs are never modified in place, so conf can be read
+	// without holding the lock.
+	return lookupPath(reflect.ValueOf(conf), path, b.opts.tagName)
+}
+
+// GetString returns the string at a dotted key path in the current
+// config, such as "server.host". It returns false if the path doesn't
+// exist or isn't a string.
+func (b *ConfigLoader[Config]) GetString(path string) (string, bool) {
+	v, ok := b.lookup(path)
+	if !ok || v.Kind() != reflect.String {
+		return "", false
+	}
+	return v.String(), true
+}
+
+// GetInt is like GetString for integers.
+func (b *ConfigLoader[Config]) GetInt(path string) (int64, bool) {
+	v, ok := b.lookup(path)
+	if !ok || v.Type() == durationType {
+		return 0, false
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	}
+	return 0, false
+}
+
+// GetBool is like GetString for booleans.
+func (b *ConfigLoader[Config]) GetBool(path string) (bool, bool) {
+	v, ok := b.lookup(path)
+	if !ok || v.Kind() != reflect.Bool {
+		return false, false
+	}
+	return v.Bool(), true
+}
+
+// GetDuration is like GetString for time.Duration values.
+func (b *ConfigLoader[Config]) GetDuration(path string) (time.Duration, bool) {
+	v, ok := b.lookup(path)
+	if !ok || v.Type() != durationType {
+		return 0, false
+	}
+	return time.Duration(v.Int()), true
+}
@@ -0,0 +1,57 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type GetConf struct {
+	Name   string
+	Server struct {
+		Host    string        `yaml:"hostname"`
+		Port    int           `yaml:"port"`
+		TLS     bool          `yaml:"tls"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"server"`
+	Labels map[string]string
+}
+
+func TestGet(t *testing.T) {
+	loader, err := NewConfigLoader[GetConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	yaml := "name: app\nserver:\n  hostname: example.com\n  port: 8080\n  tls: true\n  timeout: 5s\nlabels:\n  env: prod\n"
+	if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	if got, ok := loader.GetString("server.hostname"); !ok || got != "example.com" {
+		t.Errorf("expected server.hostname = 'example.com', got %q, %v", got, ok)
+	}
+	if got, ok := loader.GetInt("server.port"); !ok || got != 8080 {
+		t.Errorf("expected server.port = 8080, got %d, %v", got, ok)
+	}
+	if got, ok := loader.GetBool("server.tls"); !ok || !got {
+		t.Errorf("expected server.tls = true, got %v, %v", got, ok)
+	}
+	if got, ok := loader.GetDuration("server.timeout"); !ok || got != 5*time.Second {
+		t.Errorf("expected server.timeout = 5s, got %v, %v", got, ok)
+	}
+	if got, ok := loader.GetString("labels.env"); !ok || got != "prod" {
+		t.Errorf("expected labels.env = 'prod', got %q, %v", got, ok)
+	}
+
+	if _, ok := loader.GetString("server.missing"); ok {
+		t.Errorf("expected a missing path to return false")
+	}
+	if _, ok := loader.GetInt("server.hostname"); ok {
+		t.Errorf("expected a type mismatch to return false")
+	}
+	if _, ok := loader.GetInt("server.timeout"); ok {
+		t.Errorf("expected a duration not to be returned as an int")
+	}
+}
@@ -0,0 +1,60 @@
+package configloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// trimGzipExt returns path without a ".gz" extension, so that the
+// decoder can be picked by the extension of the compressed file.
+func trimGzipExt(path string) string {
+	if ext := filepath.Ext(path); strings.EqualFold(ext, ".gz") {
+		return strings.TrimSuffix(path, ext)
+	}
+	return path
+}
+
+// gunzipConfig decompresses data, read from path, if it is gzipped, going
+// by its header rather than its name. Like the compressed file, the
+// decompressed config may be at most maxSize bytes.
+func gunzipConfig(path string, data []byte, maxSize int64) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress config %q: %w", path, err)
+	}
+	defer zr.Close()
+	data, err = io.ReadAll(io.LimitReader(zr, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress config %q: %w", path, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("config %q is more than the maximum of %d bytes decompressed", path, maxSize)
+	}
+	return data, nil
+}
+
+// gzipConfig compresses data if path has a ".gz" extension.
+func gzipConfig(path string, data []byte) ([]byte, error) {
+	if trimGzipExt(path) == path {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
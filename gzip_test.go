@@ -0,0 +1,79 @@
+package configloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGzipConfig(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml.gz")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	if conf := loader.Config(); conf.Foo != "foo!" || conf.Bar != "bar!" {
+		t.Errorf("unexpected config: %+v", conf)
+	}
+	plain, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer plain.Close()
+	if loader.Fingerprint() != plain.Fingerprint() {
+		t.Errorf("expected the fingerprint to be of the decompressed contents")
+	}
+}
+
+func TestGzipRecompressionIsNotAChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml.gz")
+	write := func(level int) {
+		t.Helper()
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			t.Fatalf("error compressing config: %v", err)
+		}
+		zw.Write([]byte("foo: \"one\"\nbar: \"bar!\"\n"))
+		zw.Close()
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("error writing config: %v", err)
+		}
+	}
+	write(gzip.BestSpeed)
+
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	write(gzip.BestCompression)
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no broadcast for recompressed contents, got %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := loader.WriteConfig(TestConf{Foo: "two", Bar: "bar!"}); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Errorf("expected WriteConfig to gzip a .gz path")
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", got)
+	}
+}
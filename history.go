@@ -0,0 +1,73 @@
+package configloader
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigSnapshot is a previously loaded config.
+type ConfigSnapshot[Config any] struct {
+	Config      Config
+	Fingerprint string
+	Loaded      time.Time
+}
+
+// remember must be called with b.mu held. It adds conf to the history,
+// dropping the oldest entry if the history is full.
+func (b *ConfigLoader[Config]) remember(conf *Config, fprint string) {
+	if b.opts.historySize == 0 {
+		return
+	}
+	if len(b.history) == b.opts.historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, ConfigSnapshot[Config]{
+		Config:      *conf,
+		Fingerprint: fprint,
+		Loaded:      time.Now(),
+	})
+}
+
+// History returns the most recently loaded configs, oldest first. The
+// last entry is the current config unless it was set by Rollback. As with
+// Config, the configs are copies if WithDeepCopy or WithCloneFunc is set.
+func (b *ConfigLoader[Config]) History() []ConfigSnapshot[Config] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ret := append([]ConfigSnapshot[Config](nil), b.history...)
+	for i := range ret {
+		ret[i].Config = b.copyConf(ret[i].Config)
+	}
+	return ret
+}
+
+// Rollback makes the config from History with the given fingerprint
+// current again, and broadcasts it. The rollback is in memory only: the
+// config source is not modified, and the rolled back config stays in
+// effect until the source changes again.
+func (b *ConfigLoader[Config]) Rollback(fingerprint string) error {
+	return b.update(func() error {
+		for i := len(b.history) - 1; i >= 0; i-- {
+			snap := b.history[i]
+			if snap.Fingerprint != fingerprint {
+				continue
+			}
+			if b.fprint == fingerprint {
+				return nil
+			}
+			// The current config may itself have been rolled back or
+			// injected, in which case the source is what it replaced.
+			source := b.fprint
+			if b.rolledBack != "" {
+				source = b.rolledBack
+			}
+			conf := snap.Config
+			b.store(&conf, fingerprint)
+			if fingerprint != source {
+				b.rolledBack = source
+			}
+			return nil
+		}
+		return fmt.Errorf("no config with fingerprint %q in history", fingerprint)
+	})
+}
@@ -0,0 +1,94 @@
+package configloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryAndRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"v0\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithHistorySize(3))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	for i := 1; i <= 3; i++ {
+		writeConfig(t, path, fmt.Sprintf("foo: \"v%d\"\nbar: \"bar!\"\n", i))
+		loader.Reload()
+	}
+
+	history := loader.History()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d", len(history))
+	}
+	if history[0].Config.Foo != "v1" || history[2].Config.Foo != "v3" {
+		t.Errorf("expected history v1..v3, got %q..%q", history[0].Config.Foo, history[2].Config.Foo)
+	}
+
+	ch := loader.Subscribe()
+	<-ch
+	if err := loader.Rollback(history[0].Fingerprint); err != nil {
+		t.Fatalf("error rolling back: %v", err)
+	}
+	if got := (<-ch).Foo; got != "v1" {
+		t.Errorf("expected rollback to broadcast 'v1', got %q", got)
+	}
+
+	// The unchanged file must not undo the rollback.
+	loader.Reload()
+	if got := loader.Config().Foo; got != "v1" {
+		t.Errorf("expected rollback to survive an unchanged reload, got %q", got)
+	}
+
+	// A new change to the file replaces it.
+	writeConfig(t, path, "foo: \"v4\"\nbar: \"bar!\"\n")
+	loader.Reload()
+	if got := loader.Config().Foo; got != "v4" {
+		t.Errorf("expected 'foo' = 'v4', got %q", got)
+	}
+
+	if err := loader.Rollback("nope"); err == nil {
+		t.Errorf("expected an error for an unknown fingerprint")
+	}
+}
+
+func TestRollbackTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithHistorySize(3), WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	for _, foo := range []string{"two", "three"} {
+		writeConfig(t, path, fmt.Sprintf("foo: %q\nbar: \"bar!\"\n", foo))
+		loader.Reload()
+	}
+	history := loader.History()
+
+	for _, i := range []int{1, 0} {
+		if err := loader.Rollback(history[i].Fingerprint); err != nil {
+			t.Fatalf("error rolling back: %v", err)
+		}
+	}
+	// The second rollback must still know the file holds "three".
+	loader.Reload()
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected the rollbacks to survive an unchanged reload, got %q", got)
+	}
+
+	// Rolling back to what the file holds ends the rollback.
+	if err := loader.Rollback(history[2].Fingerprint); err != nil {
+		t.Fatalf("error rolling back: %v", err)
+	}
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader.Reload()
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected the file's change to be loaded, got %q", got)
+	}
+}
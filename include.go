@@ -0,0 +1,110 @@
+package configloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth limits how deeply include directives may nest.
+const maxIncludeDepth = 10
+
+// expandIncludes must be called with b.mu held. It returns the documents
+// for the config file at path in merge order: the files it includes,
+// recursively expanded, followed by the file itself with the include
+// directive removed. stack holds the files that include this one.
+func (b *ConfigLoader[Config]) expandIncludes(path string, data []byte, stack []string) (docs [][]byte, found []string, err error) {
+	for _, p := range stack {
+		if p == path {
+			return nil, nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, nil, fmt.Errorf("includes nested more than %d deep at %q", maxIncludeDepth, path)
+	}
+	stack = append(stack, path)
+
+	if b.opts.template {
+		var err error
+		if data, err = renderTemplate(path, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dec := decoderForPath(&b.opts, path)
+	var raw any
+	if err := dec.Unmarshal(data, &raw); err != nil {
+		return nil, nil, transientError{fmt.Errorf("could not read config %q: %w", path, err)}
+	}
+	m, ok := normalizeValue(raw).(map[string]any)
+	if !ok {
+		return [][]byte{data}, []string{path}, nil
+	}
+	directive, ok := m[b.opts.includeKey]
+	if !ok {
+		return [][]byte{data}, []string{path}, nil
+	}
+	includes, err := includeList(directive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %q in %q: %v", b.opts.includeKey, path, err)
+	}
+
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		b.included = append(b.included, inc)
+		incData, err := b.readFile(inc)
+		if err != nil {
+			return nil, nil, err
+		}
+		d, f, err := b.expandIncludes(inc, incData, stack)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, d...)
+		found = append(found, f...)
+	}
+
+	// Decode the file's own keys without the directive, which the config
+	// type doesn't know about.
+	delete(m, b.opts.includeKey)
+	own, err := dec.Marshal(m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not re-encode config %q: %v", path, err)
+	}
+	return append(docs, own), append(found, path), nil
+}
+
+// includeList interprets an include directive: a file name or a list of
+// them.
+func includeList(directive any) ([]string, error) {
+	switch d := directive.(type) {
+	case string:
+		return []string{d}, nil
+	case []any:
+		list := make([]string, len(d))
+		for i, v := range d {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a file name, got %v", v)
+			}
+			list[i] = s
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a file name or a list of them, got %v", directive)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatalf("error creating dir: %v", err)
+	}
+	fragment := filepath.Join(dir, "conf.d", "base.yaml")
+	writeConfig(t, fragment, "foo: \"base\"\nbar: \"base\"\n")
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "include: [conf.d/base.yaml]\nbar: \"main\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path, WithIncludes("include"), WithStrict(true))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Foo != "base" {
+		t.Errorf("expected included 'foo' = 'base', got %q", conf.Foo)
+	}
+	if conf.Bar != "main" {
+		t.Errorf("expected main file to take precedence, got %q", conf.Bar)
+	}
+
+	ch := loader.Subscribe()
+	<-ch
+
+	writeConfig(t, fragment, "foo: \"edited\"\nbar: \"base\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "edited" {
+			t.Errorf("expected 'foo' = 'edited', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reload after editing an included file")
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	writeConfig(t, a, "include: b.yaml\nfoo: \"a\"\n")
+	writeConfig(t, b, "include: a.yaml\nbar: \"b\"\n")
+
+	loader, err := NewConfigLoader[TestConf](a, WithIncludes("include"))
+	if loader != nil {
+		defer loader.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected an include cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package configloader
+
+import (
+	"fmt"
+)
+
+// InjectConfig makes conf the current config and broadcasts it, without
+// reading, validating or running callbacks on it. It is meant for tests of
+// code that depends on a loader, and for other programmatic control.
+//
+// The fingerprint of an injected config is computed over conf marshaled
+// with the loader's decoder. As with Rollback, the injected config stays
+// in effect until the config source changes: the watcher only replaces it
+// once the source's fingerprint differs from what it was when conf was
+// injected.
+func (b *ConfigLoader[Config]) InjectConfig(conf Config) error {
+	data, err := b.opts.decoder.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("could not marshal config: %v", err)
+	}
+	return b.update(func() error {
+		fprint := b.opts.fingerprint(data)
+		if fprint == b.fprint {
+			return nil
+		}
+		source := b.fprint
+		if b.rolledBack != "" {
+			source = b.rolledBack
+		}
+		c := b.copyConf(conf)
+		b.store(&c, fprint)
+		b.rolledBack = source
+		return nil
+	})
+}
+
+// ApplyBytes is like InjectConfig, but takes a config in the loader's
+// format and runs it through the same pipeline as a loaded file:
+// templates, schema, decoding, defaults, env overrides, required fields
+// and callbacks. If any of them fails, the error is returned and nothing
+// changes. The config is applied in memory only; the config path and the
+// file are left alone, and as with InjectConfig, the next change to the
+// file replaces it. As with SetConfigReader, include directives are not
+// expanded.
+func (b *ConfigLoader[Config]) ApplyBytes(data []byte) error {
+	return b.update(func() error {
+		conf, err := b.decodeDocs([][]byte{data}, []string{"<bytes>"}, true)
+		if err != nil {
+			return err
+		}
+		fprint := b.opts.fingerprint(data)
+		if fprint == b.fprint {
+			return nil
+		}
+		source := b.fprint
+		if b.rolledBack != "" {
+			source = b.rolledBack
+		}
+		b.store(conf, fprint)
+		b.rolledBack = source
+		return nil
+	})
+}
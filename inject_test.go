@@ -0,0 +1,98 @@
+package configloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"file\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := loader.InjectConfig(TestConf{Foo: "injected"}); err != nil {
+		t.Fatalf("error injecting config: %v", err)
+	}
+	if conf := <-ch; conf.Foo != "injected" {
+		t.Errorf("expected injected config to be broadcast, got %+v", conf)
+	}
+
+	// An unchanged source doesn't undo the injection.
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "injected" {
+		t.Errorf("expected injected config to survive a reload, got %q", got)
+	}
+
+	writeConfig(t, path, "foo: \"changed\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "changed" {
+			t.Errorf("expected 'foo' = 'changed', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for file change to override injected config")
+	}
+}
+
+func TestApplyBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"file\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	loader.RegisterCallback(func(conf TestConf) (TestConf, error) {
+		if conf.Foo == "bad" {
+			return conf, errors.New("bad foo")
+		}
+		return conf, nil
+	})
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := loader.ApplyBytes([]byte("foo: \"applied\"\nbar: \"bar!\"\n")); err != nil {
+		t.Fatalf("error applying config: %v", err)
+	}
+	if conf := <-ch; conf.Foo != "applied" {
+		t.Errorf("expected applied config to be broadcast, got %+v", conf)
+	}
+	fprint := loader.Fingerprint()
+	for _, data := range []string{"foo: [\"not a string\"]\n", "foo: \"bad\"\nbar: \"bar!\"\n"} {
+		if err := loader.ApplyBytes([]byte(data)); err == nil {
+			t.Errorf("expected an error applying %q", data)
+		}
+	}
+	if got := loader.Config().Foo; got != "applied" || loader.Fingerprint() != fprint {
+		t.Errorf("expected a failed apply to change nothing, got %q", got)
+	}
+	if got, _ := loader.ConfigPath(); got != path {
+		t.Errorf("expected the config path to be kept, got %q", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "file") {
+		t.Errorf("expected the file to be left alone, got %q, %v", data, err)
+	}
+
+	writeConfig(t, path, "foo: \"changed\"\nbar: \"bar!\"\n")
+	select {
+	case conf := <-ch:
+		if conf.Foo != "changed" {
+			t.Errorf("expected 'foo' = 'changed', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for file change to override applied config")
+	}
+}
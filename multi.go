@@ -0,0 +1,202 @@
+package configloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// MultiLoader manages several independent config files, each loaded into
+// its own type by a section loader, behind a single watcher goroutine. See
+// Section.
+type MultiLoader struct {
+	mu       sync.Mutex
+	files    map[string]string
+	opts     []Option
+	sections map[string]multiSection
+	closed   bool
+
+	mode      string
+	logger    Logger
+	control   chan string
+	sigs      chan os.Signal
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// multiSection is a section loader, whatever its config type.
+type multiSection interface {
+	watchTarget
+	Close()
+	Done() <-chan struct{}
+}
+
+// NewMultiLoader creates a loader for the config files in files, keyed by
+// section name. opts apply to every section; those that affect watching,
+// such as WithFS, WithPollInterval and WithReloadSignal, apply to the
+// shared watcher. Sections are loaded when first requested with Section,
+// since only then is their type known.
+func NewMultiLoader(files map[string]string, opts ...Option) (*MultiLoader, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, fmt.Errorf("invalid option: %v", err)
+		}
+	}
+	if err := o.validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %v", err)
+	}
+	if o.pathSet {
+		return nil, fmt.Errorf("invalid options: WithPath can't be used with a MultiLoader")
+	}
+	if o.noWatch {
+		return nil, fmt.Errorf("invalid options: WithoutWatch can't be used with a MultiLoader")
+	}
+
+	m := &MultiLoader{
+		files:    make(map[string]string, len(files)),
+		opts:     opts,
+		sections: map[string]multiSection{},
+		logger:   o.logger,
+		control:  make(chan string, 1),
+		stopped:  make(chan struct{}),
+	}
+	for name, path := range files {
+		m.files[name] = path
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	var w *fsnotify.Watcher
+	var err error
+	if o.fsys != nil {
+		m.mode = WatchModeNone
+	} else if w, err = fsnotify.NewWatcher(); err != nil {
+		m.logger.Printf("fsnotify error, falling back to polling: %v", err)
+		m.mode = WatchModePolling
+	} else {
+		m.mode = WatchModeFsnotify
+	}
+
+	if o.reloadSignal != nil {
+		m.sigs = make(chan os.Signal, 1)
+		signal.Notify(m.sigs, o.reloadSignal)
+	}
+
+	go m.watch(w, o)
+
+	return m, nil
+}
+
+// Section returns the loader for the named section, loading it into a
+// Config the first time it is requested. Later calls return the same
+// loader, and fail if Config differs. opts are applied after those given
+// to NewMultiLoader, e.g. for options such as WithCloneFunc that depend
+// on the section's type.
+//
+// Like NewConfigLoader, Section might return an error and a valid loader.
+func Section[Config any](m *MultiLoader, name string, opts ...Option) (*ConfigLoader[Config], error) {
+	loader, err := section[Config](m, name, opts)
+	if loader != nil {
+		// This must be done with m.mu released, since the watcher takes it
+		// to find the sections.
+		loader.waitRewatch()
+	}
+	return loader, err
+}
+
+// section returns the loader for the named section, adding it if needed.
+func section[Config any](m *MultiLoader, name string, opts []Option) (*ConfigLoader[Config], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, fmt.Errorf("multi loader is closed")
+	}
+	if s, ok := m.sections[name]; ok {
+		loader, ok := s.(*ConfigLoader[Config])
+		if !ok {
+			return nil, fmt.Errorf("section %q is already loaded as %T", name, s)
+		}
+		return loader, nil
+	}
+	path, ok := m.files[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown section %q", name)
+	}
+
+	all := append(append([]Option(nil), m.opts...), opts...)
+	loader, err := newConfigLoader[Config](m.ctx, path, true, nil, m, all)
+	if loader == nil {
+		return nil, err
+	}
+	m.sections[name] = loader
+
+	// The loader asked for its paths to be watched while loading, which
+	// may have been handled before it was added to sections.
+	select {
+	case m.control <- "update":
+	default:
+	}
+	return loader, err
+}
+
+// Close closes every section and stops the watcher. It is safe to call
+// more than once.
+func (m *MultiLoader) Close() {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.closed = true
+		m.mu.Unlock()
+		m.cancel()
+		<-m.stopped
+	})
+}
+
+// targets returns the sections that haven't been closed.
+func (m *MultiLoader) targets() []watchTarget {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	targets := make([]watchTarget, 0, len(m.sections))
+	for _, s := range m.sections {
+		select {
+		case <-s.Done():
+			continue
+		default:
+		}
+		targets = append(targets, s)
+	}
+	return targets
+}
+
+// watch reloads the sections as their files change until the loader is
+// closed, then closes them. w is nil if changes must be polled for
+// instead. o holds the options that apply to the watcher.
+func (m *MultiLoader) watch(w *fsnotify.Watcher, o options) {
+	defer close(m.stopped)
+	defer func() {
+		m.mu.Lock()
+		m.closed = true
+		sections := m.sections
+		m.mu.Unlock()
+		for _, s := range sections {
+			s.Close()
+		}
+	}()
+	if m.sigs != nil {
+		defer signal.Stop(m.sigs)
+	}
+	(&watcher{
+		ctx:     m.ctx,
+		logf:    m.logger.Printf,
+		fsys:    o.fsys != nil,
+		control: m.control,
+		sigs:    m.sigs,
+		targets: m.targets,
+		jitter:  o.pollJitter,
+	}).run(w)
+}
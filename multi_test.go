@@ -0,0 +1,84 @@
+package configloader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type otherConf struct {
+	Port int
+}
+
+func TestMultiLoader(t *testing.T) {
+	dir := t.TempDir()
+	testPath := filepath.Join(dir, "test.yaml")
+	otherPath := filepath.Join(dir, "other.yaml")
+	writeConfig(t, testPath, "foo: \"one\"\nbar: \"bar!\"\n")
+	writeConfig(t, otherPath, "port: 8080\n# padding\n")
+
+	m, err := NewMultiLoader(map[string]string{"test": testPath, "other": otherPath})
+	if err != nil {
+		t.Fatalf("error creating multi loader: %v", err)
+	}
+	defer m.Close()
+
+	test, err := Section[TestConf](m, "test")
+	if err != nil {
+		t.Fatalf("error loading section: %v", err)
+	}
+	other, err := Section[otherConf](m, "other")
+	if err != nil {
+		t.Fatalf("error loading section: %v", err)
+	}
+	if got := test.Config().Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+	if got := other.Config().Port; got != 8080 {
+		t.Errorf("expected 'port' = 8080, got %d", got)
+	}
+
+	if again, err := Section[TestConf](m, "test"); err != nil || again != test {
+		t.Errorf("expected the same section loader, got %p, %v", again, err)
+	}
+	if _, err := Section[otherConf](m, "test"); err == nil {
+		t.Errorf("expected an error for a section of the wrong type")
+	}
+	if _, err := Section[TestConf](m, "missing"); err == nil {
+		t.Errorf("expected an error for an unknown section")
+	}
+
+	testCh := test.Subscribe()
+	otherCh := other.Subscribe()
+	<-testCh
+	<-otherCh
+	writeConfig(t, otherPath, "port: 9090\n# padding\n")
+	select {
+	case conf := <-otherCh:
+		if conf.Port != 9090 {
+			t.Errorf("expected 'port' = 9090, got %d", conf.Port)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for section reload")
+	}
+	select {
+	case conf := <-testCh:
+		t.Errorf("expected no update for an unchanged section, got %+v", conf)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.Close()
+	for name, ch := range map[string]<-chan struct{}{"test": test.Done(), "other": other.Done()} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Errorf("expected section %q to be closed with the multi loader", name)
+		}
+	}
+	if _, ok := <-testCh; ok {
+		t.Errorf("expected subscriber channel to be closed")
+	}
+	if _, err := Section[TestConf](m, "test"); err == nil {
+		t.Errorf("expected an error after Close")
+	}
+}
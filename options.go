@@ -0,0 +1,771 @@
+package configloader
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Option configures optional behaviour of a ConfigLoader at construction.
+type Option func(*options) error
+
+type options struct {
+	decoder      Decoder
+	decoderSet   bool
+	pollInterval time.Duration
+	envOverride  bool
+	envPrefix    string
+	strict       bool
+	reloadSignal os.Signal
+
+	subscribeBuffer int
+	schema          *jsonschema.Schema
+	historySize     int
+	retryAttempts   int
+	retryBackoff    time.Duration
+	deepCopy        bool
+	clone           any // func(Config) Config
+	securePerms     bool
+	includeKey      string
+	template        bool
+	fingerprint     func([]byte) string
+	semanticChanges bool
+	fsys            fs.FS
+	statCheck       bool
+	maxSize         int64
+	onMissing       any // func() (Config, error)
+	partialDecode   any // func(map[string]any, error) (Config, error)
+	envSeparators   envSeparators
+	loadTimeout     time.Duration
+	eagerDefault    bool
+	logger          Logger
+	path            string
+	pathSet         bool
+	tagName         string
+	changeLogging   bool
+	noWatch         bool
+	minBroadcast    time.Duration
+	rejectionPolicy RejectionPolicy
+	onFatal         func(error)
+	allowEmpty      bool
+	watchEvents     int
+	pollJitter      float64
+	shadow          func(decode func(any) error) error
+
+	caseInsensitiveKeys bool
+
+	// set records the options given, so that giving one twice is an
+	// error rather than the last one silently winning.
+	set map[string]bool
+}
+
+// defaultPollInterval is the poll interval used unless WithPollInterval
+// says otherwise.
+const defaultPollInterval = 10 * time.Second
+
+func defaultOptions() options {
+	return options{
+		decoder:      YAMLDecoder{},
+		pollInterval: defaultPollInterval,
+
+		subscribeBuffer: 1,
+		historySize:     5,
+		retryAttempts:   3,
+		retryBackoff:    50 * time.Millisecond,
+		fingerprint:     sha256Fingerprint,
+		logger:          log.Default(),
+		maxSize:         10 << 20,
+		envSeparators:   envSeparators{list: ",", keyValue: "="},
+		tagName:         "yaml",
+
+		set: map[string]bool{},
+	}
+}
+
+// once returns an error if the option name has already been given.
+func (o *options) once(name string) error {
+	if o.set[name] {
+		return fmt.Errorf("conflicting options: %s given more than once", name)
+	}
+	o.set[name] = true
+	return nil
+}
+
+// validate checks for combinations of options that can't work together.
+func (o *options) validate() error {
+	// TOMLDecoder, picked by extension, is always a StrictDecoder.
+	if _, ok := o.decoder.(StrictDecoder); o.strict && !ok {
+		return fmt.Errorf("strict mode requires a StrictDecoder, got %T", o.decoder)
+	}
+	if o.noWatch && o.reloadSignal != nil {
+		return fmt.Errorf("WithReloadSignal needs a watcher, but WithoutWatch was given")
+	}
+	if o.deepCopy && o.clone != nil {
+		return fmt.Errorf("WithDeepCopy and WithCloneFunc both copy configs; give only one")
+	}
+	if o.onFatal != nil && o.rejectionPolicy != Halt {
+		return fmt.Errorf("WithOnFatal is only called by WithRejectionPolicy(Halt)")
+	}
+	return nil
+}
+
+// WithDecoder sets the Decoder used to parse config files. The default
+// is YAML, or TOML for files ending in ".toml".
+func WithDecoder(d Decoder) Option {
+	return func(o *options) error {
+		if err := o.once("WithDecoder"); err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("nil decoder")
+		}
+		o.decoder = d
+		o.decoderSet = true
+		return nil
+	}
+}
+
+// WithPollInterval sets how often the config file is re-read, both as a
+// safety net alongside fsnotify and when fsnotify is unavailable. The
+// default is 10 seconds.
+//
+// An interval of 0 disables the safety net, saving the periodic reads at
+// the cost of relying entirely on filesystem events, which can be missed
+// on some filesystems. The loader still polls, at the default interval,
+// if fsnotify can't be set up or the config comes from a URL.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) error {
+		if err := o.once("WithPollInterval"); err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("poll interval must not be negative, got %v", d)
+		}
+		o.pollInterval = d
+		return nil
+	}
+}
+
+// WithEnvOverride enables overriding config fields from the environment.
+// After a config is decoded, every exported field tagged `env:"NAME"` is
+// set from the variable prefix+NAME if it is present. Nested structs are
+// walked; unexported fields are skipped. Supported field types are
+// string, integers, floats, bool and time.Duration, and slices of them
+// and maps from string to them, which are written as comma-separated lists
+// like "a.com,b.com" and "env=prod,team=infra"; see WithEnvSeparators.
+func WithEnvOverride(prefix string) Option {
+	return func(o *options) error {
+		if err := o.once("WithEnvOverride"); err != nil {
+			return err
+		}
+		o.envOverride = true
+		o.envPrefix = prefix
+		return nil
+	}
+}
+
+// WithStrict makes unknown keys in a config file an error, so that a
+// typo'd key rejects the config rather than being silently ignored. The
+// decoder must implement StrictDecoder.
+func WithStrict(strict bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithStrict"); err != nil {
+			return err
+		}
+		o.strict = strict
+		return nil
+	}
+}
+
+// WithReloadSignal reloads the config whenever the process receives sig,
+// typically syscall.SIGHUP. The handler is removed when the loader is
+// closed. By default no signal handler is installed.
+func WithReloadSignal(sig os.Signal) Option {
+	return func(o *options) error {
+		if err := o.once("WithReloadSignal"); err != nil {
+			return err
+		}
+		if sig == nil {
+			return fmt.Errorf("nil reload signal")
+		}
+		o.reloadSignal = sig
+		return nil
+	}
+}
+
+// WithSubscribeBuffer sets the buffer size of channels returned by
+// Subscribe and SubscribeBlocking. The default is 1.
+func WithSubscribeBuffer(n int) Option {
+	return func(o *options) error {
+		if err := o.once("WithSubscribeBuffer"); err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("subscribe buffer must be at least 1, got %d", n)
+		}
+		o.subscribeBuffer = n
+		return nil
+	}
+}
+
+// WithJSONSchema validates every config against a JSON Schema before it
+// is decoded. The raw config is converted to JSON for validation, and a
+// config that violates the schema is rejected with an error listing each
+// violation. The schema runs before any callbacks.
+func WithJSONSchema(schema []byte) Option {
+	return func(o *options) error {
+		if err := o.once("WithJSONSchema"); err != nil {
+			return err
+		}
+		s, err := jsonschema.CompileString("config.schema.json", string(schema))
+		if err != nil {
+			return fmt.Errorf("invalid JSON schema: %v", err)
+		}
+		o.schema = s
+		return nil
+	}
+}
+
+// WithHistorySize sets how many recently loaded configs are kept for
+// History and Rollback. The default is 5; 0 disables the history.
+func WithHistorySize(n int) Option {
+	return func(o *options) error {
+		if err := o.once("WithHistorySize"); err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("history size must not be negative, got %d", n)
+		}
+		o.historySize = n
+		return nil
+	}
+}
+
+// WithRetry sets how many times the config is read when a file event
+// arrives, if reading or decoding it fails, and how long to wait between
+// attempts. This guards against catching a file halfway through being
+// written. The default is 3 attempts, 50ms apart.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) error {
+		if err := o.once("WithRetry"); err != nil {
+			return err
+		}
+		if attempts < 1 {
+			return fmt.Errorf("retry attempts must be at least 1, got %d", attempts)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("retry backoff must not be negative, got %v", backoff)
+		}
+		o.retryAttempts = attempts
+		o.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithDeepCopy makes every caller of Config and every subscriber receive
+// its own deep copy of the config, so that maps, slices and pointers in it
+// can't be mutated by one consumer under another. Unexported fields are
+// copied shallowly; use WithCloneFunc for types that need more care.
+func WithDeepCopy(deepCopy bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithDeepCopy"); err != nil {
+			return err
+		}
+		o.deepCopy = deepCopy
+		return nil
+	}
+}
+
+// WithCloneFunc is like WithDeepCopy, but copies configs with fn. The
+// config type of fn must match the loader's.
+func WithCloneFunc[Config any](fn func(Config) Config) Option {
+	return func(o *options) error {
+		if err := o.once("WithCloneFunc"); err != nil {
+			return err
+		}
+		if fn == nil {
+			return fmt.Errorf("nil clone function")
+		}
+		o.clone = fn
+		return nil
+	}
+}
+
+// WithRequireSecurePerms refuses to load config files that are writable
+// by group or others, keeping the previous config instead.
+func WithRequireSecurePerms(require bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithRequireSecurePerms"); err != nil {
+			return err
+		}
+		o.securePerms = require
+		return nil
+	}
+}
+
+// WithIncludes enables include directives: a top-level key, such as
+// "include", naming a file or a list of files to merge in before the
+// including file's own keys, which take precedence. Relative names are
+// resolved against the including file's directory. Included files are
+// watched along with the config itself, and may include further files up
+// to a fixed depth; cycles are an error.
+func WithIncludes(key string) Option {
+	return func(o *options) error {
+		if err := o.once("WithIncludes"); err != nil {
+			return err
+		}
+		if key == "" {
+			return fmt.Errorf("empty include key")
+		}
+		o.includeKey = key
+		return nil
+	}
+}
+
+// WithTemplate renders config files as Go text/templates before they are
+// decoded, e.g. `dataDir: {{ env "DATA_DIR" }}/cache`. Templates can use
+// env, default ({{ env "X" | default "y" }}) and now. A template that
+// fails to render rejects the config.
+//
+// Changes are detected on the file contents before rendering, so a change
+// to the environment alone doesn't trigger a reload. With WithIncludes,
+// files are rendered before their includes are resolved, and the rendered
+// output is what is fingerprinted.
+func WithTemplate(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithTemplate"); err != nil {
+			return err
+		}
+		o.template = enabled
+		return nil
+	}
+}
+
+// WithFingerprint sets the function used to fingerprint a config's raw
+// bytes. A config is only reloaded and broadcast when its fingerprint
+// changes, so fn controls what counts as a change: for example, a hash of
+// the decoded form ignores edits to comments and whitespace. When a config
+// is made of several files, fn is given their contents concatenated. The
+// default is a hex-encoded SHA-256.
+func WithFingerprint(fn func([]byte) string) Option {
+	return func(o *options) error {
+		if err := o.once("WithFingerprint"); err != nil {
+			return err
+		}
+		if fn == nil {
+			return fmt.Errorf("nil fingerprint function")
+		}
+		o.fingerprint = fn
+		return nil
+	}
+}
+
+// sha256Fingerprint is the default fingerprint function.
+func sha256Fingerprint(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// WithSemanticChangeDetection compares each newly decoded config with the
+// current one, after defaults, env overrides and callbacks have been
+// applied, and only broadcasts it if they differ (by reflect.DeepEqual).
+// Edits that don't change any value, such as reordering keys, then don't
+// wake subscribers or count as a reload. Callbacks still run for them.
+func WithSemanticChangeDetection(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithSemanticChangeDetection"); err != nil {
+			return err
+		}
+		o.semanticChanges = enabled
+		return nil
+	}
+}
+
+// WithFS reads config files, including any included files, from fsys
+// rather than the OS filesystem, for example to load defaults embedded
+// with go:embed. Paths must then be valid fs.FS paths, such as
+// "config/defaults.yaml". An fs.FS can't be watched, so changes are only
+// picked up by Reload, Load or the reload signal, and WriteConfig fails.
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) error {
+		if err := o.once("WithFS"); err != nil {
+			return err
+		}
+		if fsys == nil {
+			return fmt.Errorf("nil fs.FS")
+		}
+		o.fsys = fsys
+		return nil
+	}
+}
+
+// Logger is the interface used for the loader's log output. *log.Logger
+// implements it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// WithLogger sends the loader's log output to l rather than the standard
+// logger.
+func WithLogger(l Logger) Option {
+	return func(o *options) error {
+		if err := o.once("WithLogger"); err != nil {
+			return err
+		}
+		if l == nil {
+			return fmt.Errorf("nil logger")
+		}
+		o.logger = l
+		return nil
+	}
+}
+
+// WithPath sets the config file to load, for use with NewWithOptions. It
+// conflicts with a non-empty path passed to NewConfigLoader.
+func WithPath(path string) Option {
+	return func(o *options) error {
+		if err := o.once("WithPath"); err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("empty config path")
+		}
+		o.path = path
+		o.pathSet = true
+		return nil
+	}
+}
+
+// WithStatCheck makes polls stat the config files first, and only read
+// and fingerprint them if their size or modification time has changed.
+// This saves I/O where polling is the main way changes are noticed, such
+// as on network filesystems that don't deliver fsnotify events. Files
+// whose filesystem reports no modification time are always read. A file
+// rewritten with the same size within the modification time's resolution
+// is missed by polls, though still noticed by fsnotify, Reload and
+// signals, none of which are affected by this option.
+func WithStatCheck(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithStatCheck"); err != nil {
+			return err
+		}
+		o.statCheck = enabled
+		return nil
+	}
+}
+
+// WithMaxSize sets the largest config, in bytes, that will be loaded from
+// a file or URL. Larger configs are rejected without being read in full,
+// and the previous config is kept. The default is 10MB.
+func WithMaxSize(n int64) Option {
+	return func(o *options) error {
+		if err := o.once("WithMaxSize"); err != nil {
+			return err
+		}
+		if n <= 0 {
+			return fmt.Errorf("max size must be positive, got %d", n)
+		}
+		o.maxSize = n
+		return nil
+	}
+}
+
+// WithRequiredMissingHandler sets a function to call when a required
+// config is missing, because its file doesn't exist or no path was given,
+// and no config has been loaded yet. If fn returns a config, such as a
+// compiled-in default, it is used and broadcast in place of the error;
+// if fn returns an error, the load fails as it would without a handler.
+// Once a config has been loaded, a missing file keeps it, as usual. fn is
+// called with the loader's lock held, so it must not call the loader. The
+// config type of fn must match the loader's.
+func WithRequiredMissingHandler[Config any](fn func() (Config, error)) Option {
+	return func(o *options) error {
+		if err := o.once("WithRequiredMissingHandler"); err != nil {
+			return err
+		}
+		if fn == nil {
+			return fmt.Errorf("nil missing config handler")
+		}
+		o.onMissing = fn
+		return nil
+	}
+}
+
+// WithPartialDecode sets a function to call when a config can't be decoded
+// onto the config type, e.g. because one field has the wrong type. It is
+// given the config's raw keys, with several files merged, and the decode
+// error, and may salvage what it can: if it returns a config, that config
+// goes through the callbacks and is stored as usual, while LastError and
+// SubscribeErrors report the decode error. If it returns an error, the
+// load fails as it would without it. fn is called with the loader's lock
+// held, so it must not call the loader. The config type of fn must match
+// the loader's.
+func WithPartialDecode[Config any](fn func(raw map[string]any, decodeErr error) (Config, error)) Option {
+	return func(o *options) error {
+		if err := o.once("WithPartialDecode"); err != nil {
+			return err
+		}
+		if fn == nil {
+			return fmt.Errorf("nil partial decode function")
+		}
+		o.partialDecode = fn
+		return nil
+	}
+}
+
+// WithEnvSeparators sets the separators used to split env overrides into
+// slices and maps. The defaults are "," between items and "=" between a
+// map key and its value.
+func WithEnvSeparators(list, keyValue string) Option {
+	return func(o *options) error {
+		if err := o.once("WithEnvSeparators"); err != nil {
+			return err
+		}
+		if list == "" || keyValue == "" {
+			return fmt.Errorf("empty env separator")
+		}
+		if list == keyValue {
+			return fmt.Errorf("env list and key-value separators are both %q", list)
+		}
+		o.envSeparators = envSeparators{list: list, keyValue: keyValue}
+		return nil
+	}
+}
+
+// WithLoadTimeout limits how long reading a config file or fetching a
+// config URL may take. A read that takes longer, such as on a hung network
+// filesystem, fails the load, keeping the previous config, rather than
+// stalling the loader; a file read that can't be interrupted is left to
+// finish in the background. By default there is no limit.
+func WithLoadTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if err := o.once("WithLoadTimeout"); err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("load timeout must be positive, got %v", d)
+		}
+		o.loadTimeout = d
+		return nil
+	}
+}
+
+// WithEagerDefault makes the loader start out with a default config, the
+// zero config with `default` tags applied, before anything is loaded. That
+// way Config never returns nil and Subscribe replays the default, so that
+// startup can go ahead and pick up the real config when it loads. Since a
+// config then always exists, WithRequiredMissingHandler is never called.
+func WithEagerDefault(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithEagerDefault"); err != nil {
+			return err
+		}
+		o.eagerDefault = enabled
+		return nil
+	}
+}
+
+// WithTagName maps config keys to struct fields by the given struct tag,
+// such as "json", instead of "yaml", so that a struct shared with another
+// serializer doesn't need both. Fields without the tag use their
+// lowercased name, as with yaml tags. The tag also names the keys in
+// dotted paths, such as for GetString and required fields. It applies to
+// the YAML decoder; WriteConfig and RedactedString still marshal with yaml
+// tags, which WithTagName reads back.
+func WithTagName(name string) Option {
+	return func(o *options) error {
+		if err := o.once("WithTagName"); err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("empty tag name")
+		}
+		o.tagName = name
+		return nil
+	}
+}
+
+// WithChangeLogging logs a summary of the fields that changed, as reported
+// by Diff, whenever a new config is loaded, e.g.
+// "changed: server.timeout (30s→1m0s); added: features.beta (true)".
+// Secrets are redacted.
+func WithChangeLogging(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithChangeLogging"); err != nil {
+			return err
+		}
+		o.changeLogging = enabled
+		return nil
+	}
+}
+
+// WithMinBroadcastInterval limits broadcasts to subscribers to at most one
+// per interval d, to protect them from a source that changes too often.
+// A config loaded sooner is stored, so Config returns it at once, but is
+// only broadcast when the interval is up; if several arrive in the
+// meantime, only the latest is. Unlike a debounce, a source that keeps
+// changing still gets an update out every interval. The default, 0, is no
+// limit.
+func WithMinBroadcastInterval(d time.Duration) Option {
+	return func(o *options) error {
+		if err := o.once("WithMinBroadcastInterval"); err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("minimum broadcast interval must not be negative, got %v", d)
+		}
+		o.minBroadcast = d
+		return nil
+	}
+}
+
+// WithRejectionPolicy sets what happens when a callback, such as one from
+// RegisterCallback or RegisterRawCallback, rejects a config. The load
+// returns the callback's error under every policy; see RejectionPolicy.
+func WithRejectionPolicy(policy RejectionPolicy) Option {
+	return func(o *options) error {
+		if err := o.once("WithRejectionPolicy"); err != nil {
+			return err
+		}
+		switch policy {
+		case RetainPrevious, ServeDefault, Halt:
+		default:
+			return fmt.Errorf("unknown rejection policy %d", policy)
+		}
+		o.rejectionPolicy = policy
+		return nil
+	}
+}
+
+// WithOnFatal sets a function to call with the error when the Halt
+// rejection policy stops the loader, e.g. to exit the program. It runs on
+// its own goroutine, before the loader is closed.
+func WithOnFatal(fn func(err error)) Option {
+	return func(o *options) error {
+		if err := o.once("WithOnFatal"); err != nil {
+			return err
+		}
+		if fn == nil {
+			return fmt.Errorf("nil fatal error handler")
+		}
+		o.onFatal = fn
+		return nil
+	}
+}
+
+// WithAllowEmpty accepts a config file that is empty or holds only
+// whitespace, decoding it to the default config, with nothing set but the
+// defaults from `default` tags, which then goes through the callbacks as
+// usual. By default such a file is taken to be caught mid-write, and is
+// an error that is retried. A missing required file is an error either
+// way. Since a file that is truncated before being rewritten in place
+// briefly looks empty, a config written that way may flip to the default
+// config for a moment; writing to a temporary file and renaming it over
+// the config avoids that.
+func WithAllowEmpty(allow bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithAllowEmpty"); err != nil {
+			return err
+		}
+		o.allowEmpty = allow
+		return nil
+	}
+}
+
+// WithPollJitter randomizes each poll interval by up to ±fraction of it,
+// so that many instances polling the same shared storage don't all hit it
+// at once. fraction must be at least 0 and less than 1. The default, 0,
+// polls at exactly the interval.
+func WithPollJitter(fraction float64) Option {
+	return func(o *options) error {
+		if err := o.once("WithPollJitter"); err != nil {
+			return err
+		}
+		if fraction < 0 || fraction >= 1 {
+			return fmt.Errorf("poll jitter must be in [0, 1), got %v", fraction)
+		}
+		o.pollJitter = fraction
+		return nil
+	}
+}
+
+// WithShadowDecode also decodes each config into Shadow, after it has
+// decoded into the loader's config type, and passes the result to
+// onResult, e.g. to check that live configs fit a new version of the
+// config type before switching to it. The shadow decode goes through
+// defaults, env overrides and the required field check like the real
+// one, but its outcome never affects the loader's config. onResult is
+// called with the loader's lock held, so it must not call the loader; a
+// panic in it is logged and otherwise ignored.
+func WithShadowDecode[Shadow any](onResult func(Shadow, error)) Option {
+	return func(o *options) error {
+		if err := o.once("WithShadowDecode"); err != nil {
+			return err
+		}
+		if onResult == nil {
+			return fmt.Errorf("nil shadow decode function")
+		}
+		o.shadow = func(decode func(any) error) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("shadow decode handler panicked: %v", r)
+				}
+			}()
+			var shadow Shadow
+			derr := decode(&shadow)
+			onResult(shadow, derr)
+			return nil
+		}
+		return nil
+	}
+}
+
+// WithCaseInsensitiveKeys lets keys in YAML configs match their fields
+// ignoring case, so that "FOO" or "Foo" still sets the field named "foo".
+// A key with the exact name wins over one that differs in case. Each key
+// matched this way is logged as a warning, so that it can be fixed.
+// Other decoders are left alone; encoding/json, for one, already ignores
+// case.
+func WithCaseInsensitiveKeys(enabled bool) Option {
+	return func(o *options) error {
+		if err := o.once("WithCaseInsensitiveKeys"); err != nil {
+			return err
+		}
+		o.caseInsensitiveKeys = enabled
+		return nil
+	}
+}
+
+// WithWatchEvents makes WatchEvents report the file events the watcher
+// sees, in a channel with room for buffer events.
+func WithWatchEvents(buffer int) Option {
+	return func(o *options) error {
+		if err := o.once("WithWatchEvents"); err != nil {
+			return err
+		}
+		if buffer < 1 {
+			return fmt.Errorf("watch event buffer must be at least 1, got %d", buffer)
+		}
+		o.watchEvents = buffer
+		return nil
+	}
+}
+
+// WithoutWatch loads the config without starting a watcher goroutine, for
+// programs such as short-lived tools that load it once. The config is only
+// reloaded by explicit calls such as Reload, which still broadcast to
+// subscribers, and Close has nothing to stop. It can't be combined with
+// WithReloadSignal.
+func WithoutWatch() Option {
+	return func(o *options) error {
+		if err := o.once("WithoutWatch"); err != nil {
+			return err
+		}
+		o.noWatch = true
+		return nil
+	}
+}
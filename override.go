@@ -0,0 +1,186 @@
+package configloader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SetOverride sets the field at a dotted key path, such as
+// "features.new_ui", to value in every config loaded from now on, e.g.
+// to flip a feature flag without editing the file. Overrides are applied
+// after decoding, defaults and env overrides, but before the callbacks,
+// and take precedence over the file until cleared with ClearOverride.
+// The current config is reloaded with the override applied and
+// broadcast; if that fails, the override is kept for the next load.
+//
+// value must be assignable to the field, or be a number for a numeric
+// field or a duration string, such as "5s", for a time.Duration. Maps
+// with string keys along the path are created as needed.
+func (b *ConfigLoader[Config]) SetOverride(path string, value any) error {
+	if path == "" {
+		return fmt.Errorf("empty override path")
+	}
+	// Try the override on a scratch config, so that one that doesn't fit
+	// the config type is refused up front rather than failing every load.
+	var scratch Config
+	if err := setPath(reflect.ValueOf(&scratch).Elem(), strings.Split(path, "."), b.opts.tagName, value); err != nil {
+		return fmt.Errorf("invalid override %q: %w", path, err)
+	}
+	return b.update(func() error {
+		if b.overrides == nil {
+			b.overrides = map[string]any{}
+		}
+		b.overrides[path] = value
+		return b.reloadOverrides()
+	})
+}
+
+// ClearOverride removes the override at path set by SetOverride, and
+// reloads the config without it.
+func (b *ConfigLoader[Config]) ClearOverride(path string) error {
+	return b.update(func() error {
+		if _, ok := b.overrides[path]; !ok {
+			return nil
+		}
+		delete(b.overrides, path)
+		return b.reloadOverrides()
+	})
+}
+
+// Overrides returns the overrides set by SetOverride, by key path.
+func (b *ConfigLoader[Config]) Overrides() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ret := make(map[string]any, len(b.overrides))
+	for path, value := range b.overrides {
+		ret[path] = value
+	}
+	return ret
+}
+
+// reloadOverrides must be called with b.mu held. It reloads the config
+// after the overrides changed, decoding it even though the source
+// hasn't.
+func (b *ConfigLoader[Config]) reloadOverrides() error {
+	b.forceDecode = true
+	defer func() { b.forceDecode = false }()
+	return b.load()
+}
+
+// applyOverrides must be called with b.mu held. It applies the overrides
+// to conf, shortest path first, so that an override of a field wins over
+// one of the struct or map holding it.
+func (b *ConfigLoader[Config]) applyOverrides(conf *Config) error {
+	paths := make([]string, 0, len(b.overrides))
+	for path := range b.overrides {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	for _, path := range paths {
+		if err := setPath(reflect.ValueOf(conf).Elem(), strings.Split(path, "."), b.opts.tagName, b.overrides[path]); err != nil {
+			return fmt.Errorf("could not apply override %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// setPath sets the value at path under v, which must be settable, to
+// value. Struct fields are matched by their config key (see fieldKey),
+// and maps with string keys by key; nil pointers and maps on the way are
+// allocated.
+func setPath(v reflect.Value, path []string, tagName string, value any) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return assignValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.IsExported() && fieldKey(field, tagName) == path[0] {
+				return setPath(v.Field(i), path[1:], tagName, value)
+			}
+		}
+		return fmt.Errorf("no field %q", path[0])
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot index %s by %q", v.Type(), path[0])
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		// Map elements aren't settable, so set a copy and store it.
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if old := v.MapIndex(key); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := setPath(elem, path[1:], tagName, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("no key %q", path[0])
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := setPath(elem, path, tagName, value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	default:
+		return fmt.Errorf("cannot set %q in a %s", path[0], v.Type())
+	}
+}
+
+// assignValue sets v to value, converting between numeric types and
+// parsing duration strings.
+func assignValue(v reflect.Value, value any) error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case v.Type() == durationType && rv.Kind() == reflect.String:
+		d, err := time.ParseDuration(rv.String())
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case (rv.Kind() == v.Kind() || isNumeric(rv.Kind()) && isNumeric(v.Kind())) && rv.Type().ConvertibleTo(v.Type()):
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("cannot use %T as %s", value, v.Type())
+	}
+	return nil
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package configloader
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetOverride(t *testing.T) {
+	type overrideConf struct {
+		Name     string          `yaml:"name"`
+		Timeout  time.Duration   `yaml:"timeout"`
+		Workers  int             `yaml:"workers"`
+		Features map[string]bool `yaml:"features"`
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "name: api\nworkers: 2\nfeatures:\n  old_ui: true\n")
+	loader, err := NewConfigLoader[overrideConf](path, WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	ch := loader.Subscribe()
+	<-ch
+
+	if err := loader.SetOverride("features.new_ui", true); err != nil {
+		t.Fatalf("error setting override: %v", err)
+	}
+	if err := loader.SetOverride("timeout", "5s"); err != nil {
+		t.Fatalf("error setting override: %v", err)
+	}
+	if err := loader.SetOverride("workers", 8.0); err != nil {
+		t.Fatalf("error setting override: %v", err)
+	}
+	for _, bad := range []struct {
+		path  string
+		value any
+	}{{"missing", 1}, {"name", 1}, {"workers.count", 1}} {
+		if err := loader.SetOverride(bad.path, bad.value); err == nil {
+			t.Errorf("expected an error overriding %q with %v", bad.path, bad.value)
+		}
+	}
+	conf := loader.Config()
+	want := map[string]bool{"old_ui": true, "new_ui": true}
+	if !reflect.DeepEqual(conf.Features, want) || conf.Timeout != 5*time.Second || conf.Workers != 8 {
+		t.Errorf("expected the overrides to be applied, got %+v", conf)
+	}
+	if got := loader.Overrides(); len(got) != 3 || got["features.new_ui"] != true {
+		t.Errorf("unexpected overrides: %v", got)
+	}
+
+	// Drain the broadcasts of the overrides.
+	for len(ch) > 0 {
+		<-ch
+	}
+	writeConfig(t, path, "name: web\nworkers: 4\nfeatures:\n  new_ui: false\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("error reloading config: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Name != "web" || !conf.Features["new_ui"] || conf.Workers != 8 {
+			t.Errorf("expected the overrides to survive a file change, got %+v", conf)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the file change")
+	}
+
+	if err := loader.ClearOverride("features.new_ui"); err != nil {
+		t.Fatalf("error clearing override: %v", err)
+	}
+	if conf := loader.Config(); conf.Features["new_ui"] || conf.Workers != 8 {
+		t.Errorf("expected only the cleared override to be gone, got %+v", conf)
+	}
+}
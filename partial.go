@@ -0,0 +1,66 @@
+package configloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// unmarshalError marks a config document that could not be decoded onto
+// the config type, as opposed to one that decoded but failed a later
+// check, such as for required fields.
+type unmarshalError struct {
+	err error
+}
+
+func (e unmarshalError) Error() string {
+	return e.err.Error()
+}
+
+func (e unmarshalError) Unwrap() error {
+	return e.err
+}
+
+// salvage must be called with b.mu held. If err is a failure to decode
+// docs onto the config type and WithPartialDecode was given, it returns
+// the config the function salvages from the raw docs. Otherwise, or if
+// the function fails, it returns nil.
+func (b *ConfigLoader[Config]) salvage(docs [][]byte, found []string, err error) *Config {
+	var ue unmarshalError
+	if b.partialDecode == nil || !errors.As(err, &ue) {
+		return nil
+	}
+	var merged any = map[string]any{}
+	for i, data := range docs {
+		var doc any
+		if err := decoderForPath(&b.opts, found[i]).Unmarshal(data, &doc); err != nil {
+			b.logf("could not read config %q for partial decode: %v", found[i], err)
+			return nil
+		}
+		if doc != nil {
+			merged = mergeValues(merged, normalizeValue(doc))
+		}
+	}
+	raw, ok := merged.(map[string]any)
+	if !ok {
+		b.logf("could not read config %q for partial decode: not a mapping", strings.Join(found, ", "))
+		return nil
+	}
+	conf, perr := runPartialDecode(b.partialDecode, raw, err)
+	if perr != nil {
+		b.logf("partial decode of config %q failed: %v", strings.Join(found, ", "), perr)
+		return nil
+	}
+	return &conf
+}
+
+// runPartialDecode runs a WithPartialDecode function, turning a panic into
+// an error.
+func runPartialDecode[Config any](fn func(map[string]any, error) (Config, error), raw map[string]any, decodeErr error) (conf Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("partial decode panicked: %v", r)
+		}
+	}()
+	return fn(raw, decodeErr)
+}
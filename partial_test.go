@@ -0,0 +1,61 @@
+package configloader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPartialDecode(t *testing.T) {
+	type partialConf struct {
+		Name    string `yaml:"name"`
+		Port    int    `yaml:"port"`
+		Verbose bool   `yaml:"verbose"`
+	}
+	salvage := func(raw map[string]any, decodeErr error) (partialConf, error) {
+		var conf partialConf
+		if name, ok := raw["name"].(string); ok {
+			conf.Name = name
+		}
+		if port, ok := raw["port"].(int); ok {
+			conf.Port = port
+		}
+		if conf.Name == "" {
+			return conf, errors.New("nothing to salvage")
+		}
+		return conf, nil
+	}
+	loader, err := NewConfigLoader[partialConf]("", WithPartialDecode(salvage))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader("name: api\nport: 8080\nverbose: maybe\n"), true); err != nil {
+		t.Fatalf("expected the config to be salvaged, got %v", err)
+	}
+	if conf := loader.Config(); conf.Name != "api" || conf.Port != 8080 {
+		t.Errorf("unexpected salvaged config: %+v", conf)
+	}
+	if err := loader.LastError(); err == nil || !strings.Contains(err.Error(), "verbose") {
+		t.Errorf("expected LastError to report the decode error, got %v", err)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("port: 9090\nverbose: maybe\n"), true); err == nil {
+		t.Errorf("expected an error when nothing can be salvaged")
+	}
+	if conf := loader.Config(); conf.Port != 8080 {
+		t.Errorf("expected the salvaged config to be kept, got %+v", conf)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("name: api\nport: 9090\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if err := loader.LastError(); err != nil {
+		t.Errorf("expected no error after a clean load, got %v", err)
+	}
+
+	if _, err := NewConfigLoader[TestConf]("", WithPartialDecode(salvage)); err == nil {
+		t.Errorf("expected an error for a partial decode function of another config type")
+	}
+}
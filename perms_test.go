@@ -0,0 +1,39 @@
+//go:build unix
+
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequireSecurePerms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatalf("error setting mode: %v", err)
+	}
+
+	loader, err := NewConfigLoader[TestConf](path, WithRequireSecurePerms(true))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	if err := os.Chmod(path, 0o666); err != nil {
+		t.Fatalf("error setting mode: %v", err)
+	}
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	err = loader.Reload()
+	if err == nil {
+		t.Fatalf("expected a world-writable config to be rejected")
+	}
+	if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "022") {
+		t.Errorf("expected error to name the file and mode bits, got %v", err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package configloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// errDerivedLoader is returned when loading a loader derived by Project.
+var errDerivedLoader = errors.New("cannot load a derived config loader")
+
+// Project returns a read-only loader whose config is extract applied to
+// parent's, e.g. to give a plugin only its own section. It is updated
+// whenever parent loads a new config, but only broadcasts when the
+// projected value changes, as compared by reflect.DeepEqual. Config,
+// Subscribe and the other read methods work as usual; Load, WriteConfig
+// and the other methods that change the config return an error.
+//
+// The derived loader shares parent's options and is closed along with
+// it. Project is a function rather than a method because Go methods can't
+// have type parameters.
+func Project[Config, Sub any](parent *ConfigLoader[Config], extract func(Config) Sub) *ConfigLoader[Sub] {
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	o := parent.opts
+	// These depend on the config type, which is now Sub.
+	o.clone, o.onMissing, o.partialDecode = nil, nil, nil
+	child := &ConfigLoader[Sub]{
+		control: make(chan string, 1),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+		opts:    o,
+		derived: true,
+	}
+	child.ctx, child.cancel = context.WithCancel(parent.ctx)
+	child.stats.WatchMode = parent.stats.WatchMode
+	// There is no watcher to stop.
+	close(child.stopped)
+
+	// set must be called with child.mu held.
+	set := func(sub Sub) {
+		data, err := o.decoder.Marshal(sub)
+		if err != nil {
+			// The Go syntax is enough to tell values apart.
+			data = []byte(fmt.Sprintf("%#v", sub))
+		}
+		child.source = parent.changedBy
+		child.store(&sub, o.fingerprint(data))
+		child.stats.ReloadCount++
+		child.stats.LastSuccess = time.Now()
+		child.remember(&sub, child.fprint)
+	}
+	project := func(conf Config) {
+		sub, err := runExtract(extract, conf)
+		if err != nil {
+			child.logf("could not project config: %v", err)
+			return
+		}
+		child.apply(func() error {
+			if !child.closed && (child.conf == nil || !reflect.DeepEqual(*child.conf, sub)) {
+				set(sub)
+			}
+			return nil
+		})
+	}
+
+	if parent.closed {
+		child.Close()
+		return child
+	}
+	if parent.conf != nil {
+		// There are no subscribers to deliver to yet, so the config can
+		// be set without apply, which would need parent.mu released.
+		if sub, err := runExtract(extract, parent.copyConf(*parent.conf)); err != nil {
+			child.logf("could not project config: %v", err)
+		} else {
+			set(sub)
+		}
+	}
+	parent.projections = append(parent.projections, func(conf Config) {
+		project(parent.copyConf(conf))
+	})
+	parent.children = append(parent.children, child.Close)
+	return child
+}
+
+// runExtract runs extract on conf, turning a panic into an error.
+func runExtract[Config, Sub any](extract func(Config) Sub, conf Config) (sub Sub, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("extract panicked: %v", r)
+		}
+	}()
+	return extract(conf), nil
+}
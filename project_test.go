@@ -0,0 +1,63 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProject(t *testing.T) {
+	parent, err := NewConfigLoader[TestConf]("")
+	if parent == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer parent.Close()
+	load := func(yaml string) {
+		t.Helper()
+		if err := parent.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+	}
+	load("foo: \"one\"\nbar: \"bar!\"\n")
+
+	sub := Project(parent, func(c TestConf) string { return c.Foo })
+	if got := *sub.Config(); got != "one" {
+		t.Errorf("expected projected 'one', got %q", got)
+	}
+	ch := sub.Subscribe()
+	<-ch
+
+	load("foo: \"one\"\nbar: \"changed\"\n")
+	select {
+	case got := <-ch:
+		t.Errorf("expected no broadcast for an unchanged projection, got %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	load("foo: \"two\"\nbar: \"changed\"\n")
+	select {
+	case got := <-ch:
+		if got != "two" {
+			t.Errorf("expected projected 'two', got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for projected config")
+	}
+
+	if err := sub.Reload(); err == nil {
+		t.Errorf("expected an error reloading a derived loader")
+	}
+	if err := sub.SetConfigPath("testdata/config.yaml"); err == nil {
+		t.Errorf("expected an error setting the path of a derived loader")
+	}
+
+	parent.Close()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected the derived loader to close with its parent")
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("expected subscriber channel to be closed")
+	}
+}
@@ -0,0 +1,109 @@
+package configloader
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// redacted replaces the value of secret fields in RedactedString.
+const redacted = "***"
+
+// isSecret reports whether field is tagged `secret:"true"`.
+func isSecret(field reflect.StructField) bool {
+	secret, _ := strconv.ParseBool(field.Tag.Get("secret"))
+	return secret
+}
+
+// redactValue returns v with every field tagged `secret:"true"` replaced
+// by "***", and whether anything was replaced. Structs that contain
+// secrets become maps keyed by config key (see fieldKey); anything else is
+// returned as is, so it marshals the same way it normally would.
+func redactValue(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface(), false
+		}
+		if r, changed := redactValue(v.Elem()); changed {
+			return r, true
+		}
+	case reflect.Struct:
+		m := make(map[string]any)
+		changed := false
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+				continue
+			}
+			fv := v.Field(i)
+			if strings.Contains(field.Tag.Get("yaml"), "omitempty") && fv.IsZero() {
+				continue
+			}
+			if isSecret(field) {
+				m[fieldKey(field, "yaml")] = redacted
+				changed = true
+				continue
+			}
+			r, c := redactValue(fv)
+			m[fieldKey(field, "yaml")] = r
+			changed = changed || c
+		}
+		if changed {
+			return m, true
+		}
+	case reflect.Slice, reflect.Array:
+		s := make([]any, v.Len())
+		changed := false
+		for i := range s {
+			var c bool
+			s[i], c = redactValue(v.Index(i))
+			changed = changed || c
+		}
+		if changed {
+			return s, true
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]any, v.Len())
+		changed := false
+		iter := v.MapRange()
+		for iter.Next() {
+			var c bool
+			m[iter.Key().String()], c = redactValue(iter.Value())
+			changed = changed || c
+		}
+		if changed {
+			return m, true
+		}
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), false
+}
+
+// RedactedString returns the current config marshaled with the loader's
+// decoder, with every field tagged `secret:"true"` replaced by "***". It
+// is meant for printing the config while debugging; it returns "" if no
+// config has been loaded.
+func (b *ConfigLoader[Config]) RedactedString() string {
+	b.mu.Lock()
+	conf := b.conf
+	b.mu.Unlock()
+	if conf == nil {
+		return ""
+	}
+	// Loaded configs are never modified in place, so conf can be read
+	// without holding the lock.
+	r, _ := redactValue(reflect.ValueOf(conf).Elem())
+	data, err := b.opts.decoder.Marshal(r)
+	if err != nil {
+		return fmt.Sprintf("<could not marshal config: %v>", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,49 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+)
+
+type secretConf struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password" secret:"true"`
+	Database struct {
+		Host  string `yaml:"host"`
+		Token int    `yaml:"token" secret:"true"`
+	} `yaml:"database"`
+}
+
+func TestRedactedString(t *testing.T) {
+	loader, err := NewConfigLoader[secretConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if got := loader.RedactedString(); got != "" {
+		t.Errorf("expected empty string before load, got %q", got)
+	}
+
+	yaml := "user: admin\npassword: hunter2\ndatabase:\n  host: db.local\n  token: 123456\n"
+	if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	got := loader.RedactedString()
+	for _, secret := range []string{"hunter2", "123456"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redacted config contains secret %q:\n%s", secret, got)
+		}
+	}
+	for _, want := range []string{"user: admin", "password: '***'", "host: db.local", "token: '***'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected redacted config to contain %q:\n%s", want, got)
+		}
+	}
+
+	// The loaded config itself is untouched.
+	if conf := loader.Config(); conf.Password != "hunter2" || conf.Database.Token != 123456 {
+		t.Errorf("redaction modified the loaded config: %+v", conf)
+	}
+}
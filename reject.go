@@ -0,0 +1,84 @@
+package configloader
+
+import (
+	"errors"
+)
+
+// RejectionPolicy says what happens when a callback rejects a config.
+type RejectionPolicy int
+
+const (
+	// RetainPrevious keeps serving the config in effect, as if the
+	// rejected one had never been loaded. Nothing changes for the rest
+	// of the program, which is the safest choice when the last good
+	// config is still usable, but the rejection only shows up in
+	// LastError, SubscribeErrors and the log.
+	RetainPrevious RejectionPolicy = iota
+	// ServeDefault reverts to the default config, with nothing set but
+	// the defaults from `default` tags, and broadcasts it. This suits
+	// configs whose defaults are safe, but discards whatever was right
+	// in the config in effect.
+	ServeDefault
+	// Halt fails closed: it calls the WithOnFatal function, if any, and
+	// closes the loader, so that no further config is loaded and
+	// subscriber channels are closed. Config keeps returning the config
+	// in effect. This is for programs that would rather stop than run on
+	// a config that is known to be out of date.
+	Halt
+)
+
+// rejectionError marks a config rejected by a callback.
+type rejectionError struct {
+	err error
+}
+
+func (e rejectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e rejectionError) Unwrap() error {
+	return e.err
+}
+
+// handleRejection must be called with b.mu held. If err is a callback
+// rejecting a config, it applies the WithRejectionPolicy policy. It
+// returns err.
+func (b *ConfigLoader[Config]) handleRejection(err error) error {
+	var re rejectionError
+	if !errors.As(err, &re) {
+		return err
+	}
+	switch b.opts.rejectionPolicy {
+	case ServeDefault:
+		if b.conf != nil && b.origin == Default {
+			return err
+		}
+		conf, derr := b.defaultConfig()
+		if derr != nil {
+			b.logf("could not serve the default config: %v", derr)
+			return err
+		}
+		data, merr := b.opts.decoder.Marshal(*conf)
+		if merr != nil {
+			b.logf("could not marshal the default config: %v", merr)
+			return err
+		}
+		b.logf("%v; serving the default config", err)
+		b.store(conf, b.opts.fingerprint(data))
+		b.origin = Default
+		b.stats.ReloadCount++
+	case Halt:
+		b.haltOnce.Do(func() {
+			b.logf("%v; closing config loader", err)
+			// Close waits for the update in progress, which holds the
+			// lock, so it has to happen after load returns.
+			go func() {
+				if b.opts.onFatal != nil {
+					b.opts.onFatal(err)
+				}
+				b.Close()
+			}()
+		})
+	}
+	return err
+}
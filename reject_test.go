@@ -0,0 +1,106 @@
+package configloader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRejectionPolicy(t *testing.T) {
+	type rejectConf struct {
+		Mode string `yaml:"mode" default:"safe"`
+	}
+	newLoader := func(t *testing.T, opts ...Option) (*ConfigLoader[rejectConf], func(string) error) {
+		loader, err := NewConfigLoader[rejectConf]("", opts...)
+		if loader == nil {
+			t.Fatalf("error creating config loader: %v", err)
+		}
+		t.Cleanup(loader.Close)
+		loader.RegisterCallback(func(conf rejectConf) (rejectConf, error) {
+			if conf.Mode == "bad" {
+				return conf, errors.New("bad mode")
+			}
+			return conf, nil
+		})
+		load := func(mode string) error {
+			return loader.SetConfigReader(strings.NewReader("mode: "+mode+"\n# padding\n"), true)
+		}
+		if err := load("fast"); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+		return loader, load
+	}
+
+	t.Run("RetainPrevious", func(t *testing.T) {
+		loader, load := newLoader(t)
+		if err := load("bad"); err == nil {
+			t.Errorf("expected the config to be rejected")
+		}
+		if got := loader.Config().Mode; got != "fast" {
+			t.Errorf("expected the previous config to be kept, got %q", got)
+		}
+		if got := loader.ConfigSource(); got != PreviousCached {
+			t.Errorf("expected the config to be PreviousCached, got %v", got)
+		}
+	})
+
+	t.Run("ServeDefault", func(t *testing.T) {
+		loader, load := newLoader(t, WithRejectionPolicy(ServeDefault))
+		ch := loader.Subscribe()
+		<-ch
+		if err := load("bad"); err == nil {
+			t.Errorf("expected the config to be rejected")
+		}
+		select {
+		case conf := <-ch:
+			if conf.Mode != "safe" {
+				t.Errorf("expected the default config, got %+v", conf)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the default config")
+		}
+		if got := loader.ConfigSource(); got != Default {
+			t.Errorf("expected the config to be Default, got %v", got)
+		}
+		if err := load("fast"); err != nil {
+			t.Fatalf("error loading config: %v", err)
+		}
+		if got := loader.Config().Mode; got != "fast" {
+			t.Errorf("expected a good config to replace the default, got %q", got)
+		}
+	})
+
+	t.Run("Halt", func(t *testing.T) {
+		fatal := make(chan error, 1)
+		loader, load := newLoader(t, WithRejectionPolicy(Halt), WithOnFatal(func(err error) { fatal <- err }))
+		ch := loader.Subscribe()
+		<-ch
+		if err := load("bad"); err == nil {
+			t.Errorf("expected the config to be rejected")
+		}
+		select {
+		case err := <-fatal:
+			if !strings.Contains(err.Error(), "bad mode") {
+				t.Errorf("expected the rejection to be passed on, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the fatal error handler")
+		}
+		select {
+		case <-loader.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("expected the loader to close")
+		}
+		if _, ok := <-ch; ok {
+			t.Errorf("expected subscriber channel to be closed")
+		}
+		if got := loader.Config().Mode; got != "fast" {
+			t.Errorf("expected the previous config to still be served, got %q", got)
+		}
+	})
+
+	if _, err := NewConfigLoader[rejectConf]("", WithOnFatal(func(error) {})); err == nil {
+		t.Errorf("expected an error for WithOnFatal without Halt")
+	}
+}
@@ -0,0 +1,59 @@
+package configloader
+
+import (
+	"reflect"
+	"strings"
+)
+
+// fieldKey returns the config key for a struct field: the name from its
+// tag named tagName, usually "yaml", if there is one, otherwise the
+// lowercased field name, as gopkg.in/yaml.v3 does.
+func fieldKey(field reflect.StructField, tagName string) string {
+	if tag := field.Tag.Get(tagName); tag != "" {
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// hasTagOption reports whether the `configloader` tag of field contains opt.
+func hasTagOption(field reflect.StructField, opt string) bool {
+	for _, o := range strings.Split(field.Tag.Get("configloader"), ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// missingRequired returns the dotted key paths of every field of v tagged
+// `configloader:"required"` that is still zero. Nested structs are walked
+// recursively. Keys are named by the tagName tag; see fieldKey.
+func missingRequired(v reflect.Value, prefix, tagName string) []string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var missing []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := prefix + fieldKey(field, tagName)
+		fv := v.Field(i)
+		if hasTagOption(field, "required") && fv.IsZero() {
+			missing = append(missing, path)
+			continue
+		}
+		missing = append(missing, missingRequired(fv, path+".", tagName)...)
+	}
+	return missing
+}
@@ -0,0 +1,38 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+)
+
+type RequiredConf struct {
+	Foo    string `configloader:"required"`
+	Server struct {
+		Host string `yaml:"hostname" configloader:"required"`
+		Port int
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	loader, err := NewConfigLoader[RequiredConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	err = loader.SetConfigReader(strings.NewReader("foo: \"foo!\"\nserver:\n  port: 80\n"), true)
+	if err == nil {
+		t.Fatalf("expected config missing a required field to be rejected")
+	}
+	if !strings.Contains(err.Error(), "server.hostname") {
+		t.Errorf("expected error to name server.hostname, got %v", err)
+	}
+	if strings.Contains(err.Error(), "foo") {
+		t.Errorf("expected error not to name foo, got %v", err)
+	}
+
+	err = loader.SetConfigReader(strings.NewReader("foo: \"foo!\"\nserver:\n  hostname: example.com\n"), true)
+	if err != nil {
+		t.Errorf("expected complete config to load, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package configloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// validateSchema decodes each of docs generically with the matching
+// decoder, merges them, and validates the result against schema. All
+// violations are listed in the returned error.
+func validateSchema(schema *jsonschema.Schema, decoders []Decoder, docs [][]byte) error {
+	var merged any
+	for i, doc := range docs {
+		var v any
+		if err := decoders[i].Unmarshal(doc, &v); err != nil {
+			return transientError{err}
+		}
+		merged = mergeValues(merged, normalizeValue(v))
+	}
+
+	// Round-trip through JSON so that the instance only contains the
+	// types the validator understands.
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("could not convert config to JSON: %v", err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var inst any
+	if err := d.Decode(&inst); err != nil {
+		return fmt.Errorf("could not convert config to JSON: %v", err)
+	}
+
+	err = schema.Validate(inst)
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	var violations []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			loc := e.InstanceLocation
+			if loc == "" {
+				loc = "/"
+			}
+			violations = append(violations, fmt.Sprintf("%s: %s", loc, e.Message))
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	return fmt.Errorf("schema violations: %s", strings.Join(violations, "; "))
+}
+
+// normalizeValue converts map[any]any values into map[string]any. The
+// built-in decoders produce map[string]any; map[any]any only comes from
+// custom Decoders.
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normalizeValue(val)
+		}
+		return m
+	case map[string]any:
+		for k, val := range v {
+			v[k] = normalizeValue(val)
+		}
+		return v
+	case []any:
+		for i, val := range v {
+			v[i] = normalizeValue(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// mergeValues merges src onto dst the way decoding several documents onto
+// the same struct does: maps are merged key by key, and anything else in
+// src replaces dst.
+func mergeValues(dst, src any) any {
+	dm, ok1 := dst.(map[string]any)
+	sm, ok2 := src.(map[string]any)
+	if !ok1 || !ok2 {
+		return src
+	}
+	for k, v := range sm {
+		dm[k] = mergeValues(dm[k], v)
+	}
+	return dm
+}
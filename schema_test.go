@@ -0,0 +1,51 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"foo": {"type": "string"},
+		"port": {"type": "integer", "minimum": 1}
+	},
+	"required": ["foo"]
+}`
+
+type SchemaConf struct {
+	Foo  string
+	Port int
+}
+
+func TestJSONSchema(t *testing.T) {
+	loader, err := NewConfigLoader[SchemaConf]("testdata/config.yaml", WithJSONSchema([]byte(testSchema)))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	err = loader.SetConfigReader(strings.NewReader("port: 0\nbar: \"bar!\"\n"), true)
+	if err == nil {
+		t.Fatalf("expected config violating the schema to be rejected")
+	}
+	for _, want := range []string{"foo", "/port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+	if got := loader.Config().Foo; got != "foo!" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"ok\"\nport: 8080\n"), true); err != nil {
+		t.Errorf("expected valid config to load, got %v", err)
+	}
+}
+
+func TestInvalidJSONSchema(t *testing.T) {
+	if _, err := NewConfigLoader[SchemaConf]("testdata/config.yaml", WithJSONSchema([]byte("{"))); err == nil {
+		t.Errorf("expected an error for an invalid schema")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadSignal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+
+	loader, err := NewConfigLoader[TestConf](path,
+		WithPollInterval(time.Hour), WithReloadSignal(syscall.SIGHUP))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	ch := loader.Subscribe()
+	<-ch
+
+	// Forget the fingerprint so that the reload is visible as a broadcast
+	// even though the file hasn't changed.
+	loader.mu.Lock()
+	loader.fprint = ""
+	loader.mu.Unlock()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("error sending signal: %v", err)
+	}
+	select {
+	case conf := <-ch:
+		if conf.Foo != "one" {
+			t.Errorf("expected 'foo' = 'one', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reload on signal")
+	}
+}
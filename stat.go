@@ -0,0 +1,74 @@
+package configloader
+
+import (
+	"os"
+	"time"
+)
+
+// fileStamp is what WithStatCheck compares to tell whether a config file
+// may have changed.
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// statFiles must be called with b.mu held. It stats every file the config
+// is read from, or returns nil if the config doesn't come from files.
+func (b *ConfigLoader[Config]) statFiles() map[string]fileStamp {
+	if b.url != "" || b.memSource || b.opts.fsys != nil {
+		return nil
+	}
+	paths := b.filePaths()
+	if b.glob != "" {
+		// A fragment that was added since the last load changes the
+		// set of files.
+		if matches, err := b.expandGlob(); err == nil {
+			paths = append(paths, matches...)
+		}
+	}
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			stamps[path] = fileStamp{}
+			continue
+		}
+		stamps[path] = fileStamp{exists: true, modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stamps
+}
+
+// filesUnchanged reports whether WithStatCheck is on and no config file
+// has changed size or modification time since it was last read. A file
+// without a modification time always counts as changed.
+func (b *ConfigLoader[Config]) filesUnchanged() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.opts.statCheck || b.stamps == nil {
+		return false
+	}
+	stamps := b.statFiles()
+	if len(stamps) != len(b.stamps) {
+		return false
+	}
+	for path, s := range stamps {
+		old, ok := b.stamps[path]
+		if !ok || s.exists != old.exists || s.size != old.size || !s.modTime.Equal(old.modTime) {
+			return false
+		}
+		if s.exists && s.modTime.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// pollReload reloads the config on a poll, unless the files are known
+// not to have changed.
+func (b *ConfigLoader[Config]) pollReload() {
+	if b.filesUnchanged() {
+		return
+	}
+	b.watchReload(SourcePoll, false)
+}
@@ -0,0 +1,54 @@
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithStatCheck(true))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	// Keep the watcher out of the way, so that only the stat check runs.
+	loader.Pause()
+
+	if !loader.filesUnchanged() {
+		t.Errorf("expected files to be unchanged after loading")
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error statting config: %v", err)
+	}
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	if err := os.Chtimes(path, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatalf("error setting config mtime: %v", err)
+	}
+	if !loader.filesUnchanged() {
+		t.Errorf("expected same size and mtime to be treated as unchanged")
+	}
+
+	later := fi.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("error setting config mtime: %v", err)
+	}
+	if loader.filesUnchanged() {
+		t.Errorf("expected a new mtime to be treated as changed")
+	}
+
+	if err := loader.Resume(); err != nil {
+		t.Fatalf("error resuming: %v", err)
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected 'foo' = 'two', got %q", got)
+	}
+	if !loader.filesUnchanged() {
+		t.Errorf("expected files to be unchanged after reloading")
+	}
+}
@@ -0,0 +1,219 @@
+package configloader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// retagDoc must be called with b.mu held. If WithTagName chose a tag other
+// than yaml, or WithCaseInsensitiveKeys is on, it returns data re-encoded
+// with its keys renamed to those the YAML decoder maps to the fields of v;
+// see retagger. Otherwise it returns data as is.
+//
+// srcLine maps a line of the returned data back to its line in data, so
+// that decoding errors point at what the user wrote. It is nil if data is
+// returned as is.
+func (b *ConfigLoader[Config]) retagDoc(name string, data []byte, v any) (retagged []byte, srcLine func(int) int, err error) {
+	if b.opts.tagName == "yaml" && !b.opts.caseInsensitiveKeys {
+		return data, nil, nil
+	}
+	dec := decoderForPath(&b.opts, name)
+	if _, ok := dec.(YAMLDecoder); !ok {
+		return data, nil, nil
+	}
+	// Keys are renamed in place, so that every node keeps the line it was
+	// read from.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return data, nil, nil
+	}
+	r := &retagger{tagName: b.opts.tagName, fold: b.opts.caseInsensitiveKeys, seen: map[*yaml.Node]bool{}}
+	r.retag(doc.Content[0], reflect.TypeOf(v), "")
+	if retagged, err = dec.Marshal(&doc); err != nil {
+		return nil, nil, fmt.Errorf("could not re-encode config: %v", err)
+	}
+	if len(r.folded) > 0 {
+		b.logf("warning: config %q has keys that only match ignoring case: %s", name, strings.Join(r.folded, ", "))
+	}
+	srcLine = func(n int) int {
+		var out yaml.Node
+		if yaml.Unmarshal(retagged, &out) != nil {
+			return 0
+		}
+		lines := map[int]int{}
+		mapLines(&doc, &out, lines)
+		return lines[n]
+	}
+	return retagged, srcLine, nil
+}
+
+// mapLines records in lines the line in src of each node of out, which
+// was decoded from src re-encoded, so that the two trees have the same
+// shape.
+func mapLines(src, out *yaml.Node, lines map[int]int) {
+	if _, ok := lines[out.Line]; !ok {
+		lines[out.Line] = src.Line
+	}
+	if len(src.Content) != len(out.Content) {
+		return
+	}
+	for i := range src.Content {
+		mapLines(src.Content[i], out.Content[i], lines)
+	}
+}
+
+// retagger renames the keys of a decoded config document from the names
+// given by tagName on the fields of its type to their yaml names,
+// following nested structs, slices, maps and merge keys. Keys that don't
+// name a field are kept, so a document that already uses yaml names still
+// decodes, unless a renamed key replaces them.
+type retagger struct {
+	tagName string
+	// fold matches keys to fields ignoring case, when there is no exact
+	// match. folded records each key matched that way, as "key → name".
+	fold   bool
+	folded []string
+	// seen holds the nodes already renamed, as an anchored node can be
+	// reached again through its aliases.
+	seen map[*yaml.Node]bool
+}
+
+// retag renames the keys of n, found at path, for decoding into t.
+func (r *retagger) retag(n *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if r.seen[n] {
+		return
+	}
+	r.seen[n] = true
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		r.retagMerges(n, t, path)
+		keys := make([]string, len(n.Content)/2)
+		for i := range keys {
+			keys[i] = n.Content[2*i].Value
+		}
+		renamed := map[int]string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			from, to := fieldKey(field, r.tagName), fieldKey(field, "yaml")
+			if from == "-" {
+				continue
+			}
+			k := indexOf(keys, from)
+			if k < 0 && r.fold {
+				if k = foldIndex(keys, from); k >= 0 {
+					r.folded = append(r.folded, fmt.Sprintf("%s → %s", joinPath(path, keys[k]), joinPath(path, from)))
+				}
+			}
+			if k < 0 {
+				continue
+			}
+			renamed[k] = to
+			r.retag(n.Content[2*k+1], field.Type, joinPath(path, from))
+		}
+		// Rename the matched keys, dropping any other key they replace.
+		replaced := map[string]bool{}
+		for _, to := range renamed {
+			replaced[to] = true
+		}
+		content := n.Content[:0]
+		for k, key := range keys {
+			to, ok := renamed[k]
+			if !ok && replaced[key] {
+				continue
+			}
+			if ok {
+				n.Content[2*k].Value = to
+			}
+			content = append(content, n.Content[2*k], n.Content[2*k+1])
+		}
+		n.Content = content
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			r.retag(item, t.Elem(), joinPath(path, strconv.Itoa(i)))
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		r.retagMerges(n, t, path)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if key := n.Content[i]; !isMergeKey(key) {
+				r.retag(n.Content[i+1], t.Elem(), joinPath(path, key.Value))
+			}
+		}
+	}
+}
+
+// retagMerges renames the keys of the mappings merged into n with "<<".
+func (r *retagger) retagMerges(n *yaml.Node, t reflect.Type, path string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if !isMergeKey(n.Content[i]) {
+			continue
+		}
+		if v := n.Content[i+1]; v.Kind == yaml.SequenceNode {
+			for _, item := range v.Content {
+				r.retag(item, t, path)
+			}
+		} else {
+			r.retag(v, t, path)
+		}
+	}
+}
+
+func isMergeKey(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!merge"
+}
+
+func indexOf(keys []string, name string) int {
+	for i, k := range keys {
+		if k == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// foldIndex returns the index of the key equal to name ignoring case,
+// picking the first in sorted order if there are several, or -1.
+func foldIndex(keys []string, name string) int {
+	var matches []int
+	for i, k := range keys {
+		if strings.EqualFold(k, name) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return -1
+	}
+	sort.Slice(matches, func(i, j int) bool { return keys[matches[i]] < keys[matches[j]] })
+	return matches[0]
+}
@@ -0,0 +1,104 @@
+package configloader
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestTagName(t *testing.T) {
+	type backend struct {
+		Address string `json:"address"`
+		Weight  int    `json:"weight,omitempty"`
+	}
+	type jsonConf struct {
+		ServiceName string    `json:"service_name" configloader:"required"`
+		Backends    []backend `json:"backends"`
+		Debug       bool
+	}
+	loader, err := NewConfigLoader[jsonConf]("", WithTagName("json"))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	yaml := "service_name: api\nbackends:\n  - address: a:80\n    weight: 2\n  - address: b:80\ndebug: true\n"
+	if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	conf := loader.Config()
+	if conf.ServiceName != "api" {
+		t.Errorf("expected ServiceName = 'api', got %q", conf.ServiceName)
+	}
+	if len(conf.Backends) != 2 || conf.Backends[0] != (backend{"a:80", 2}) || conf.Backends[1] != (backend{"b:80", 0}) {
+		t.Errorf("unexpected backends: %+v", conf.Backends)
+	}
+	if !conf.Debug {
+		t.Errorf("expected an untagged field to use its lowercased name")
+	}
+	if got, ok := loader.GetString("service_name"); !ok || got != "api" {
+		t.Errorf("expected service_name = 'api', got %q, %v", got, ok)
+	}
+
+	err = loader.SetConfigReader(strings.NewReader("backends: []\n# padding\n"), true)
+	if err == nil || !strings.Contains(err.Error(), "service_name") {
+		t.Errorf("expected the missing required field to be named by its json tag, got %v", err)
+	}
+}
+
+func TestTagNameDecodeError(t *testing.T) {
+	type jsonConf struct {
+		Server struct {
+			ListenPort int `json:"listen_port"`
+		} `json:"server_config"`
+		Name string `json:"name"`
+	}
+	loader, err := NewConfigLoader[jsonConf]("", WithTagName("json"), WithCaseInsensitiveKeys(true))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	// Re-encoding drops the blank line, which moves listen_port up a line.
+	yaml := "name: api\n\nserver_config:\n  Listen_Port: eighty\n"
+	err = loader.SetConfigReader(strings.NewReader(yaml), true)
+	if err == nil || !strings.Contains(err.Error(), `line 4: field "server_config.Listen_Port"`) {
+		t.Errorf("expected the error to point at the line and keys as written, got %v", err)
+	}
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	type foldConf struct {
+		Foo    string
+		Server struct {
+			Host string `yaml:"host"`
+		} `yaml:"server"`
+	}
+	var buf syncBuffer
+	loader, err := NewConfigLoader[foldConf]("", WithCaseInsensitiveKeys(true), WithLogger(log.New(&buf, "", 0)))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigReader(strings.NewReader("FOO: bar\nServer:\n  Host: example.com\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	conf := loader.Config()
+	if conf.Foo != "bar" {
+		t.Errorf("expected Foo = 'bar', got %q", conf.Foo)
+	}
+	if conf.Server.Host != "example.com" {
+		t.Errorf("expected Server.Host = 'example.com', got %q", conf.Server.Host)
+	}
+	if logged := buf.String(); !strings.Contains(logged, "FOO → foo") || !strings.Contains(logged, "server.Host → server.host") {
+		t.Errorf("expected a warning naming the folded keys, got:\n%s", logged)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("Foo: folded\nfoo: exact\n"), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if got := loader.Config().Foo; got != "exact" {
+		t.Errorf("expected the exact key to win, got %q", got)
+	}
+}
@@ -0,0 +1,38 @@
+package configloader
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions available to config templates.
+var templateFuncs = template.FuncMap{
+	// env returns the value of an environment variable, or "".
+	"env": os.Getenv,
+	// default returns def if val is empty, for use as
+	// {{ env "X" | default "fallback" }}.
+	"default": func(def, val string) string {
+		if val == "" {
+			return def
+		}
+		return val
+	},
+	// now returns the current time.
+	"now": time.Now,
+}
+
+// renderTemplate executes data, read from name, as a text/template.
+func renderTemplate(name string, data []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config template %q: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("could not render config template %q: %v", name, err)
+	}
+	return buf.Bytes(), nil
+}
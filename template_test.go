@@ -0,0 +1,35 @@
+package configloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Setenv("TEST_TEMPLATE_DIR", "/data")
+
+	loader, err := NewConfigLoader[TestConf]("", WithTemplate(true))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	yaml := "foo: \"{{ env \"TEST_TEMPLATE_DIR\" }}/cache\"\nbar: \"{{ env \"TEST_TEMPLATE_UNSET\" | default \"fallback\" }}\"\n"
+	if err := loader.SetConfigReader(strings.NewReader(yaml), true); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	conf := loader.Config()
+	if conf.Foo != "/data/cache" {
+		t.Errorf("expected 'foo' = '/data/cache', got %q", conf.Foo)
+	}
+	if conf.Bar != "fallback" {
+		t.Errorf("expected 'bar' = 'fallback', got %q", conf.Bar)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("foo: \"{{ nope }}\"\n"), true); err == nil {
+		t.Errorf("expected a broken template to be rejected")
+	}
+	if got := loader.Config().Foo; got != "/data/cache" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
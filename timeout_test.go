@@ -0,0 +1,51 @@
+//go:build unix
+
+package configloader
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLoadTimeoutFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithLoadTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	// Opening a FIFO for reading blocks until there is a writer, like a
+	// read from a hung network filesystem.
+	fifo := filepath.Join(dir, "hung.yaml")
+	if err := syscall.Mkfifo(fifo, 0o644); err != nil {
+		t.Skipf("could not create FIFO: %v", err)
+	}
+	defer func() {
+		// Unblock the abandoned read.
+		if f, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			f.Close()
+		}
+	}()
+
+	start := time.Now()
+	if err := loader.SetConfigPath(fifo); err == nil {
+		t.Errorf("expected a hung read to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the read to be abandoned after the timeout, took %v", elapsed)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+
+	// The loader is still responsive.
+	if err := loader.SetConfigPath(path); err != nil {
+		t.Errorf("error loading config after a timeout: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package configloader
+
+import (
+	"context"
+	"fmt"
+)
+
+// NewTransformingLoader creates a loader whose config files are decoded
+// into Raw, such as a flat schema that is easy to edit, and then converted
+// into Config, the shape the program uses, by transform. Defaults, env
+// overrides and required fields apply to Raw; callbacks, fingerprints and
+// subscribers see Config. If transform returns an error, the config is
+// rejected. Since a Config can't be turned back into a Raw, WriteConfig
+// always fails.
+func NewTransformingLoader[Raw, Config any](path string, transform func(Raw) (Config, error), opts ...Option) (*ConfigLoader[Config], error) {
+	if transform == nil {
+		return nil, fmt.Errorf("nil transform function")
+	}
+	return newConfigLoader[Config](context.Background(), path, true, func(decode func(any) error) (*Config, error) {
+		raw := new(Raw)
+		if err := decode(raw); err != nil {
+			return nil, err
+		}
+		conf, err := transform(*raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not transform config: %w", err)
+		}
+		return &conf, nil
+	}, nil, opts)
+}
@@ -0,0 +1,56 @@
+package configloader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rawTimeouts struct {
+	Read  string `yaml:"read"`
+	Write string `yaml:"write"`
+}
+
+type timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+func parseTimeouts(raw rawTimeouts) (timeouts, error) {
+	read, err := time.ParseDuration(raw.Read)
+	if err != nil {
+		return timeouts{}, err
+	}
+	write, err := time.ParseDuration(raw.Write)
+	if err != nil {
+		return timeouts{}, err
+	}
+	return timeouts{Read: read, Write: write}, nil
+}
+
+func TestTransformingLoader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "read: 5s\nwrite: 1m\n")
+
+	loader, err := NewTransformingLoader(path, parseTimeouts)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+
+	conf := loader.Config()
+	if conf.Read != 5*time.Second || conf.Write != time.Minute {
+		t.Errorf("expected 5s and 1m, got %v and %v", conf.Read, conf.Write)
+	}
+
+	if err := loader.SetConfigReader(strings.NewReader("read: soon\nwrite: 1m\n"), true); err == nil {
+		t.Errorf("expected a transform error to reject the config")
+	}
+	if got := loader.Config().Read; got != 5*time.Second {
+		t.Errorf("expected previous config to be kept, got %v", got)
+	}
+	if err := loader.WriteConfig(timeouts{}); err == nil {
+		t.Errorf("expected WriteConfig to fail for a transformed config")
+	}
+}
@@ -0,0 +1,61 @@
+package configloader
+
+import (
+	"bytes"
+	"time"
+)
+
+// TryLoad is like Reload, but all or nothing: it returns the config it
+// loaded if every step succeeds, and otherwise returns the error and
+// leaves the loader exactly as it was. On failure, nothing is stored or
+// broadcast, LastError, Stats and ConfigSource are unchanged, and neither
+// WithRequiredMissingHandler, WithRejectionPolicy nor WithPartialDecode
+// steps in. If the config source is unchanged, it returns the current
+// config.
+func (b *ConfigLoader[Config]) TryLoad() (*Config, error) {
+	var ret *Config
+	err := b.update(func() error {
+		if b.frozen {
+			b.logf("frozen, ignoring reload")
+			ret = b.copyConfPtr(b.conf)
+			return nil
+		}
+		var stamps map[string]fileStamp
+		if b.opts.statCheck {
+			stamps = b.statFiles()
+		}
+		// Reading expands globs and includes, which is undone on failure.
+		paths, included := b.paths, b.included
+		docs, found, err := b.readDocs()
+		if err != nil {
+			b.paths, b.included = paths, included
+			return err
+		}
+
+		origin := FromFile
+		if len(docs) == 0 {
+			origin = Default
+		}
+		fprint := b.opts.fingerprint(bytes.Join(docs, nil))
+		if fprint != b.fprint && fprint != b.rolledBack {
+			conf, err := b.decodeDocs(docs, found, b.opts.includeKey == "")
+			if err != nil {
+				b.paths, b.included = paths, included
+				return err
+			}
+			b.commit(conf, fprint, found, origin, nil)
+		} else if b.origin == PreviousCached {
+			b.origin = origin
+		}
+		b.stamps = stamps
+		b.lastErr = nil
+		b.partialErr = nil
+		b.stats.LastSuccess = time.Now()
+		ret = b.copyConfPtr(b.conf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
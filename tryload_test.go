@@ -0,0 +1,90 @@
+package configloader
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTryLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithPollInterval(0), WithRejectionPolicy(ServeDefault))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	loader.RegisterCallback(func(conf TestConf) (TestConf, error) {
+		if conf.Foo == "bad" {
+			return conf, errors.New("bad foo")
+		}
+		return conf, nil
+	})
+	loader.Pause()
+	ch := loader.Subscribe()
+	<-ch
+	errs := loader.SubscribeErrors()
+
+	conf, err := loader.TryLoad()
+	if err != nil || conf == nil || conf.Foo != "one" {
+		t.Errorf("expected the current config for an unchanged file, got %+v, %v", conf, err)
+	}
+
+	fprint, stats, source := loader.Fingerprint(), loader.Stats(), loader.ConfigSource()
+	for _, contents := range []string{
+		"foo: [\"not a string\"]\n",     // fails to decode
+		"foo: \"bad\"\nbar: \"bar!\"\n", // rejected by the callback
+	} {
+		writeConfig(t, path, contents)
+		if conf, err := loader.TryLoad(); err == nil || conf != nil {
+			t.Errorf("expected an error and no config for %q, got %+v, %v", contents, conf, err)
+		}
+		if got := loader.Config().Foo; got != "one" {
+			t.Errorf("expected the config to be unchanged, got %q", got)
+		}
+		if loader.Fingerprint() != fprint || loader.ConfigSource() != source || loader.LastError() != nil {
+			t.Errorf("expected the fingerprint, source and LastError to be unchanged")
+		}
+		if got := loader.Stats(); !reflect.DeepEqual(got, stats) {
+			t.Errorf("expected stats to be unchanged, got %+v, want %+v", got, stats)
+		}
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected nothing to be broadcast, got %+v", conf)
+	case err := <-errs:
+		t.Errorf("expected no error to be broadcast, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeConfig(t, path, "foo: \"two\"\nbar: \"bar!\"\n")
+	conf, err = loader.TryLoad()
+	if err != nil || conf == nil || conf.Foo != "two" {
+		t.Errorf("expected the new config, got %+v, %v", conf, err)
+	}
+	if got := (<-ch).Foo; got != "two" {
+		t.Errorf("expected the new config to be broadcast, got %q", got)
+	}
+}
+
+func TestTryLoadPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "foo: \"one\"\nbar: \"bar!\"\n")
+	loader, err := NewConfigLoader[TestConf](path, WithDecoder(panicDecoder{}), WithPollInterval(0))
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	loader.Pause()
+
+	writeConfig(t, path, "foo: \"boom\"\nbar: \"bar!\"\n")
+	if conf, err := loader.TryLoad(); err == nil || conf != nil || !strings.Contains(err.Error(), "decoder bug") {
+		t.Errorf("expected the panic as an error and no config, got %+v, %v", conf, err)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected the config to be unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,88 @@
+package configloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SetConfigURL loads the config from an HTTP(S) URL, polling it every
+// interval (or the loader's poll interval, if interval is not positive).
+// Unchanged configs are detected with ETag/If-None-Match where the server
+// supports it. Responses other than 2xx are load errors and leave the
+// previous config in effect; if required is false, a 404 serves the zero
+// config instead. Files are not watched while a URL is in use.
+func (b *ConfigLoader[Config]) SetConfigURL(url string, required bool, interval time.Duration) error {
+	if url == "" {
+		return fmt.Errorf("no config URL specified")
+	}
+	return b.update(func() error {
+		b.setPaths(nil, required)
+		b.url = url
+		b.urlInterval = interval
+		b.source = SourcePathChange
+		return b.load()
+	})
+}
+
+// fetchURL must be called with b.mu held. It returns nil data if the
+// config is optional and not found.
+func (b *ConfigLoader[Config]) fetchURL() ([]byte, error) {
+	ctx := b.ctx
+	if b.opts.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.opts.loadTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch config @ %q: %v", b.url, err)
+	}
+	if b.etag != "" && b.urlData != nil {
+		req.Header.Set("If-None-Match", b.etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch config @ %q: %v", b.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && b.urlData != nil:
+		return b.urlData, nil
+	case resp.StatusCode == http.StatusNotFound && !b.required:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("could not fetch config @ %q: %s", b.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, b.opts.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch config @ %q: %v", b.url, err)
+	}
+	if int64(len(data)) > b.opts.maxSize {
+		return nil, fmt.Errorf("config @ %q is more than the maximum of %d bytes", b.url, b.opts.maxSize)
+	}
+	b.etag = resp.Header.Get("ETag")
+	b.urlData = data
+	return data, nil
+}
+
+// pollEvery returns how long the watcher waits between polls, or 0 if it
+// shouldn't poll.
+func (b *ConfigLoader[Config]) pollEvery() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.url != "" {
+		// A URL can only be polled.
+		if b.urlInterval > 0 {
+			return b.urlInterval
+		}
+		if b.opts.pollInterval == 0 {
+			return defaultPollInterval
+		}
+	}
+	return b.opts.pollInterval
+}
@@ -0,0 +1,118 @@
+package configloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetConfigURL(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		body        = "foo: \"one\"\nbar: \"bar!\"\n"
+		version     = 1
+		status      = http.StatusOK
+		notModified atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		etag := fmt.Sprintf("%q", fmt.Sprint(version))
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	loader, err := NewConfigLoader[TestConf]("")
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+
+	if err := loader.SetConfigURL(srv.URL, true, 10*time.Millisecond); err != nil {
+		t.Fatalf("error loading config from URL: %v", err)
+	}
+	ch := loader.Subscribe()
+	if got := (<-ch).Foo; got != "one" {
+		t.Errorf("expected 'foo' = 'one', got %q", got)
+	}
+
+	mu.Lock()
+	body = "foo: \"two\"\nbar: \"bar!\"\n"
+	version++
+	mu.Unlock()
+	select {
+	case conf := <-ch:
+		if conf.Foo != "two" {
+			t.Errorf("expected 'foo' = 'two', got %q", conf.Foo)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for polled config")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if notModified.Load() == 0 {
+		t.Errorf("expected unchanged polls to use If-None-Match")
+	}
+
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	if err := loader.Reload(); err == nil {
+		t.Errorf("expected an error for a 500 response")
+	}
+	if got := loader.Config().Foo; got != "two" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
+
+func TestLoadTimeoutURL(t *testing.T) {
+	var slow atomic.Bool
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		fmt.Fprint(w, "foo: \"one\"\nbar: \"bar!\"\n")
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	loader, err := NewConfigLoader[TestConf]("", WithLoadTimeout(100*time.Millisecond))
+	if loader == nil {
+		t.Fatalf("error creating config loader: %v", err)
+	}
+	defer loader.Close()
+	if err := loader.SetConfigURL(srv.URL, true, time.Hour); err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+
+	slow.Store(true)
+	start := time.Now()
+	if err := loader.Reload(); err == nil {
+		t.Errorf("expected a hung fetch to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the fetch to be abandoned after the timeout, took %v", elapsed)
+	}
+	if got := loader.Config().Foo; got != "one" {
+		t.Errorf("expected previous config to be kept, got %q", got)
+	}
+}
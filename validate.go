@@ -0,0 +1,37 @@
+package configloader
+
+// Validate reads the config file at path and runs it through the same
+// checks as a load (includes, templates, schema, decoding, defaults, env
+// overrides, required fields and callbacks), returning the first error.
+// The loader's config, fingerprint and subscribers are untouched, so it
+// can be used to check a candidate config before deploying it. Callbacks
+// do run, so they should be free of side effects.
+func (b *ConfigLoader[Config]) Validate(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := b.readFile(path)
+	if err != nil {
+		return err
+	}
+	docs, found := [][]byte{data}, []string{path}
+	if b.opts.includeKey != "" {
+		included := b.included
+		defer func() { b.included = included }()
+		if docs, found, err = b.expandIncludes(path, data, nil); err != nil {
+			return err
+		}
+	}
+	_, err = b.decodeDocs(docs, found, b.opts.includeKey == "")
+	return err
+}
+
+// ValidateBytes is like Validate for a config held in memory, which is
+// decoded with the loader's decoder. As with SetConfigReader, include
+// directives are not expanded.
+func (b *ConfigLoader[Config]) ValidateBytes(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.decodeDocs([][]byte{data}, []string{"<bytes>"}, true)
+	return err
+}
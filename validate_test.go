@@ -0,0 +1,55 @@
+package configloader
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	loader, err := NewConfigLoader[TestConf]("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	defer loader.Close()
+	loader.AddCallback(func(c TestConf) (TestConf, error) {
+		if c.Foo == "bad" {
+			return c, errors.New("foo must not be bad")
+		}
+		return c, nil
+	})
+	ch := loader.Subscribe()
+	<-ch
+	fprint := loader.Fingerprint()
+
+	path := filepath.Join(t.TempDir(), "candidate.yaml")
+	writeConfig(t, path, "foo: \"bad\"\nbar: \"bar!\"\n")
+	if err := loader.Validate(path); err == nil {
+		t.Errorf("expected candidate config to fail validation")
+	}
+	writeConfig(t, path, "foo: \"good\"\nbar: \"bar!\"\n")
+	if err := loader.Validate(path); err != nil {
+		t.Errorf("expected candidate config to pass validation, got %v", err)
+	}
+	if err := loader.ValidateBytes([]byte("foo: [not, a, string]\n")); err == nil {
+		t.Errorf("expected undecodable bytes to fail validation")
+	}
+	if err := loader.ValidateBytes([]byte("foo: \"bad\"\n")); err == nil {
+		t.Errorf("expected rejected bytes to fail validation")
+	}
+
+	if got := loader.Config().Foo; got != "foo!" {
+		t.Errorf("expected config to be untouched, got 'foo' = %q", got)
+	}
+	if got := loader.Fingerprint(); got != fprint {
+		t.Errorf("expected fingerprint to be untouched, got %s", got)
+	}
+	select {
+	case conf := <-ch:
+		t.Errorf("expected no broadcast, got %+v", conf)
+	default:
+	}
+	if err := loader.LastError(); err != nil {
+		t.Errorf("expected no load error to be recorded, got %v", err)
+	}
+}
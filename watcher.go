@@ -0,0 +1,318 @@
+package configloader
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchTarget is a loader as seen by a watcher. It hides the config type,
+// so that one watcher can serve loaders of different types; see
+// NewMultiLoader.
+type watchTarget interface {
+	watchPaths() []string
+	pendingWatch() ([]string, chan struct{})
+	isWatchedPath(name string) bool
+	watchReload(src Source, retry bool) bool
+	watchEvent(event fsnotify.Event, reload bool)
+	pollReload()
+	pollEvery() time.Duration
+}
+
+// watcher reloads its targets as their files change, until ctx is done.
+type watcher struct {
+	ctx  context.Context
+	logf func(format string, v ...any)
+	// fsys is set if the targets read from an fs.FS, which can't be
+	// watched.
+	fsys    bool
+	control chan string
+	sigs    chan os.Signal
+	targets func() []watchTarget
+	// jitter is the fraction by which poll intervals are randomized; see
+	// WithPollJitter.
+	jitter float64
+}
+
+// watchPaths returns the paths of every target.
+func (wt *watcher) watchPaths() []string {
+	var paths []string
+	for _, t := range wt.targets() {
+		paths = append(paths, t.watchPaths()...)
+	}
+	return paths
+}
+
+// synced tells the targets' waitRewatch calls that their paths are
+// watched, or that there is nothing to watch.
+func (wt *watcher) synced() {
+	for _, t := range wt.targets() {
+		if _, done := t.pendingWatch(); done != nil {
+			close(done)
+		}
+	}
+}
+
+// pollEvery returns the shortest poll interval of any target, or 0 if
+// none of them poll.
+func (wt *watcher) pollEvery() time.Duration {
+	var every time.Duration
+	for _, t := range wt.targets() {
+		if d := t.pollEvery(); d > 0 && (every == 0 || d < every) {
+			every = d
+		}
+	}
+	return every
+}
+
+// pollAfter returns a channel that delivers when the next poll is due, or
+// nil if polling is disabled.
+func (wt *watcher) pollAfter() <-chan time.Time {
+	every := wt.pollEvery()
+	if every == 0 {
+		return nil
+	}
+	return time.After(jitter(every, wt.jitter))
+}
+
+// jitter randomizes d by up to ±fraction of it.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
+
+// reload reloads every target on behalf of src.
+func (wt *watcher) reload(src Source, retry bool) {
+	for _, t := range wt.targets() {
+		t.watchReload(src, retry)
+	}
+}
+
+// poll polls every target.
+func (wt *watcher) poll() {
+	for _, t := range wt.targets() {
+		t.pollReload()
+	}
+}
+
+// run watches until ctx is done. w is nil if changes must be polled for
+// instead.
+func (wt *watcher) run(w *fsnotify.Watcher) {
+	if wt.fsys {
+		// An fs.FS can't be watched, so only reload when asked to.
+		for {
+			select {
+			case sig := <-wt.sigs:
+				wt.logf("received %v, reloading config", sig)
+				wt.reload(SourceSignal, false)
+			case <-wt.control:
+				wt.synced()
+			case <-wt.ctx.Done():
+				wt.logf("exiting config pool loop")
+				return
+			}
+		}
+	}
+
+	if w == nil {
+		wt.logf("polling config files: %v", wt.watchPaths())
+		for {
+			every := wt.pollEvery()
+			if every == 0 {
+				// Polling is all there is, so it can't be disabled.
+				every = defaultPollInterval
+			}
+			select {
+			case <-time.After(jitter(every, wt.jitter)):
+				wt.poll()
+			case sig := <-wt.sigs:
+				wt.logf("received %v, reloading config", sig)
+				wt.reload(SourceSignal, false)
+			case <-wt.control:
+				wt.synced()
+			case <-wt.ctx.Done():
+				wt.logf("exiting config pool loop")
+				return
+			}
+		}
+	}
+
+	defer w.Close()
+
+	// Watch the directories rather than the files themselves, so that
+	// files that are replaced rather than written in place are noticed.
+	dirs := map[string]bool{}
+	rewatch := func() (complete bool) {
+		complete = true
+		want := map[string]bool{}
+		var waiting []chan struct{}
+		for _, t := range wt.targets() {
+			paths, done := t.pendingWatch()
+			for _, path := range paths {
+				want[filepath.Dir(path)] = true
+			}
+			if done != nil {
+				waiting = append(waiting, done)
+			}
+		}
+		// Directories that can't be watched yet are retried in the
+		// background, so waiters are released either way.
+		defer func() {
+			for _, done := range waiting {
+				close(done)
+			}
+		}()
+		for dir := range dirs {
+			if !want[dir] {
+				w.Remove(dir)
+				delete(dirs, dir)
+			}
+		}
+		for dir := range want {
+			if dirs[dir] {
+				continue
+			}
+			if err := w.Add(dir); err != nil {
+				wt.logf("could not watch %q: %v", dir, err)
+				complete = false
+				continue
+			}
+			wt.logf("watching config directory: %s", dir)
+			dirs[dir] = true
+		}
+		return complete
+	}
+
+	// Directories that can't be watched, because they don't exist yet or
+	// were removed, are retried with backoff until they can be.
+	var retry <-chan time.Time
+	var retryDelay time.Duration
+	scheduleRetry := func(complete bool) {
+		if complete {
+			retry, retryDelay = nil, 0
+			return
+		}
+		retryDelay *= 2
+		if retryDelay < minRewatchBackoff {
+			retryDelay = minRewatchBackoff
+		}
+		if retryDelay > maxRewatchBackoff {
+			retryDelay = maxRewatchBackoff
+		}
+		retry = time.After(retryDelay)
+	}
+
+	scheduleRetry(rewatch())
+	for {
+		select {
+		case <-wt.ctx.Done():
+			wt.logf("exiting config pool loop")
+			return
+		case cmd := <-wt.control:
+			if cmd == "update" {
+				wt.logf("updating config watch paths to: %v", wt.watchPaths())
+				scheduleRetry(rewatch())
+			}
+		case <-retry:
+			watched := len(dirs)
+			scheduleRetry(rewatch())
+			if len(dirs) > watched {
+				// The config may have been written before the watch was
+				// re-established.
+				wt.reload(SourceFsnotify, true)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				wt.logf("fsnotify closed")
+				return
+			}
+			wt.logf("fsnotify error: %v", err)
+		case event, ok := <-w.Events:
+			if !ok {
+				wt.logf("fsnotify closed")
+				return
+			}
+			// A watched directory that is removed takes its watch with it.
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				if dirs[event.Name] {
+					wt.logf("config directory %s removed, waiting for it to reappear", event.Name)
+					w.Remove(event.Name)
+					delete(dirs, event.Name)
+					scheduleRetry(false)
+					continue
+				}
+			}
+			// Editors and deploy tools often replace the file with a
+			// rename, which shows up as a Create (or a Rename of the old
+			// file) in the directory rather than a Write. Since the
+			// directory is watched, the watch survives the new inode.
+			//
+			// A Chmod may or may not come with new contents, depending on
+			// the tool and platform, so it is treated like a Write: the
+			// fingerprint check stops a chmod that leaves the contents
+			// alone from being broadcast. An event with several of these
+			// bits set still reloads only once.
+			//
+			// A removed file is reloaded too, so that a fallback takes
+			// over; a file that is removed and then recreated is covered
+			// by the retries for missing files.
+			reload := event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Chmod) || event.Has(fsnotify.Remove)
+			for _, t := range wt.targets() {
+				t.watchEvent(event, reload)
+			}
+		case sig := <-wt.sigs:
+			wt.logf("received %v, reloading config", sig)
+			wt.reload(SourceSignal, false)
+		case <-wt.pollAfter():
+			wt.poll()
+		}
+	}
+}
+
+// WatchEvent is a file event seen by the watcher, as reported by
+// WatchEvents.
+type WatchEvent struct {
+	// Name is the file the event is for, which may be any file in a
+	// watched directory.
+	Name string
+	Op   fsnotify.Op
+	// Reloaded is set if the event made the loader reload its config,
+	// whether or not the config changed.
+	Reloaded bool
+}
+
+// WatchEvents returns a channel that receives every file event the
+// watcher sees in the directories it watches, including those for other
+// files, which helps find out why an edit isn't picked up. Events are
+// dropped if the channel is full, so a slow reader can't stall the
+// watcher. The channel is closed when the loader is; it is nil, and
+// never receives anything, unless WithWatchEvents was given.
+func (b *ConfigLoader[Config]) WatchEvents() <-chan WatchEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.watchEvents
+}
+
+// watchEvent handles a file event in a watched directory, reloading the
+// config if reload is set and the event is for one of its files.
+func (b *ConfigLoader[Config]) watchEvent(event fsnotify.Event, reload bool) {
+	reloaded := false
+	if reload && b.isWatchedPath(event.Name) {
+		reloaded = b.watchReload(SourceFsnotify, true)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchEvents == nil || b.closed {
+		return
+	}
+	select {
+	case b.watchEvents <- WatchEvent{Name: event.Name, Op: event.Op, Reloaded: reloaded}:
+	default:
+	}
+}